@@ -0,0 +1,346 @@
+package gowb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/db"
+	"github.com/mj37yhyy/gowb/pkg/listener"
+	gowbLog "github.com/mj37yhyy/gowb/pkg/log"
+	"github.com/mj37yhyy/gowb/pkg/utils"
+	"github.com/mj37yhyy/gowb/pkg/web"
+)
+
+// server App管理的底层服务（gin、MCP SSE、MCP stdio）
+type server interface {
+	Start() error
+	Shutdown(ctx context.Context) error
+	Addr() string
+	Done() <-chan struct{}
+	Err() error
+}
+
+// poolOwner 当前持有db包中mysql与redis连接池的App。连接池是进程级的全局变量，
+// 同一进程中只能有一个App开启mysql或redis，其余App可以同时运行但不能开启它们
+var (
+	poolMu    sync.Mutex
+	poolOwner *App
+)
+
+// App 可嵌入、可控制的gowb应用句柄
+type App struct {
+	config           config.Config
+	values           context.Context
+	autoCreateTables []interface{}
+	hooks            Hooks
+	build            func(c context.Context) (server, error)
+
+	mu       sync.Mutex
+	server   server
+	mysql    bool
+	redis    bool
+	started  bool
+	stopOnce sync.Once
+	stopErr  error
+	done     chan struct{}
+	err      error
+}
+
+// New 创建web应用，不会启动服务
+func New(g Gowb) (*App, error) {
+	conf, err := loadConfig(g.ConfigName, g.ConfigType, g.Config)
+	if err != nil {
+		return nil, err
+	}
+	c := web.WithRouters(context.Background(), g.Routers)
+	c = web.WithGroups(c, g.Groups)
+	c = ctxkit.WithConfig(c, conf)
+	c = web.WithMiddleware(c, g.Middleware)
+	if g.RateLimitStore != nil {
+		c = web.WithRateLimitStore(c, g.RateLimitStore)
+	}
+	if g.CacheStore != nil {
+		c = web.WithCacheStore(c, g.CacheStore)
+	}
+	return &App{
+		config:           conf,
+		values:           c,
+		autoCreateTables: g.AutoCreateTables,
+		hooks:            g.Hooks,
+		build: func(c context.Context) (server, error) {
+			return web.NewServer(c)
+		},
+		done: make(chan struct{}),
+	}, nil
+}
+
+// Start 初始化资源并启动服务，监听地址绑定成功后立即返回。
+// OnReady执行时服务已在监听，钩子中可以通过Addr获取实际地址；启动失败时释放已打开的连接池，Wait返回该错误
+func (a *App) Start(ctx context.Context) error {
+	a.mu.Lock()
+	if a.started {
+		a.mu.Unlock()
+		return errors.New("app already started")
+	}
+	a.started = true
+	a.mu.Unlock()
+
+	if err := runHooks(ctx, a.hooks.BeforeInit); err != nil {
+		return a.fail(err)
+	}
+
+	if a.config.Mysql.Enabled || a.config.Redis.Enabled {
+		if err := a.claimPools(); err != nil {
+			return a.fail(err)
+		}
+	}
+
+	//初始化mysql
+	if a.config.Mysql.Enabled {
+		if err := initMysql(a.values, a.autoCreateTables); err != nil {
+			return a.fail(err)
+		}
+		a.mysql = true
+	}
+
+	//初始化redis
+	if a.config.Redis.Enabled {
+		if err := db.InitRedis(a.values); err != nil {
+			return a.fail(err)
+		}
+		a.redis = true
+	}
+
+	//初始化日志
+	if err := gowbLog.InitLogger(a.values); err != nil {
+		return a.fail(err)
+	}
+
+	if err := runHooks(ctx, a.hooks.AfterInit); err != nil {
+		return a.fail(err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return a.fail(err)
+	}
+
+	s, err := a.build(a.values)
+	if err != nil {
+		return a.fail(err)
+	}
+	if err := s.Start(); err != nil {
+		return a.fail(err)
+	}
+	a.mu.Lock()
+	a.server = s
+	a.mu.Unlock()
+
+	if err := runHooks(ctx, a.hooks.OnReady); err != nil {
+		s.Shutdown(ctx)
+		a.mu.Lock()
+		a.server = nil
+		a.mu.Unlock()
+		return a.fail(err)
+	}
+
+	go func() {
+		<-s.Done()
+		a.err = s.Err()
+		close(a.done)
+	}()
+
+	// 热重启的新进程通知父进程可以停机
+	if err := listener.NotifyReady(); err != nil {
+		log.Println("Notify parent process failed:", err)
+	}
+	return nil
+}
+
+/*
+启动失败时关闭已打开的连接池，并让Wait返回该错误
+*/
+func (a *App) fail(err error) error {
+	a.closePools()
+	a.err = err
+	close(a.done)
+	return err
+}
+
+/*
+占用进程级的连接池，已被另一个App占用时返回错误，避免两个App互相关闭对方的连接
+*/
+func (a *App) claimPools() error {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	if poolOwner != nil && poolOwner != a {
+		return errors.New("mysql/redis pools are already owned by another App in this process")
+	}
+	poolOwner = a
+	return nil
+}
+
+func (a *App) closePools() error {
+	poolMu.Lock()
+	if poolOwner == a {
+		poolOwner = nil
+	}
+	poolMu.Unlock()
+	var err error
+	if a.mysql {
+		err = db.Close()
+		a.mysql = false
+	}
+	if a.redis {
+		if redisErr := db.CloseRedis(); err == nil {
+			err = redisErr
+		}
+		a.redis = false
+	}
+	return err
+}
+
+// Stop 排空并关闭服务，依次执行关闭钩子、关闭数据库与redis连接池，ctx控制整体的最长等待时间。
+// 并发或重复调用时等待第一次调用完成并返回相同的结果
+func (a *App) Stop(ctx context.Context) error {
+	a.mu.Lock()
+	s := a.server
+	a.mu.Unlock()
+	if s == nil {
+		return nil
+	}
+
+	a.stopOnce.Do(func() {
+		err := s.Shutdown(ctx)
+		if hookErr := runShutdownHooks(ctx, a.hooks.OnShutdown); err == nil {
+			err = hookErr
+		}
+		if poolErr := a.closePools(); err == nil {
+			err = poolErr
+		}
+		a.stopErr = err
+	})
+	return a.stopErr
+}
+
+// Wait 阻塞直到服务退出，返回服务异常退出或启动失败的原因，未调用Start时立即返回错误
+func (a *App) Wait() error {
+	a.mu.Lock()
+	started := a.started
+	a.mu.Unlock()
+	if !started {
+		return errors.New("app not started")
+	}
+	<-a.done
+	return a.err
+}
+
+// Addr 服务实际监听的地址，未启动时返回空字符串
+func (a *App) Addr() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.server == nil {
+		return ""
+	}
+	return a.server.Addr()
+}
+
+// run 启动应用并阻塞等待退出信号
+func run(app *App) error {
+	if err := app.Start(context.Background()); err != nil {
+		return err
+	}
+
+	// kill (no param) default send syscall.SIGTERM
+	// kill -2 is syscall.SIGINT
+	signals := []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	if app.config.Web.GracefulRestart {
+		signals = append(signals, restartSignals...)
+	}
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, signals...)
+	defer signal.Stop(quit)
+
+	for {
+		select {
+		case sig := <-quit:
+			if isRestartSignal(sig) {
+				// 新进程接管监听socket并就绪后，本进程走正常的停机流程排空请求；
+				// 新进程启动失败时本进程继续服务
+				child, err := listener.Fork()
+				if err != nil {
+					log.Println("Restart Server failed:", err)
+					continue
+				}
+				log.Printf("Restart Server, new process %d", child.Process.Pid)
+				timeout := utils.If(app.config.Web.RestartTimeout <= 0, 30*time.Second, app.config.Web.RestartTimeout*time.Second).(time.Duration)
+				if err := child.WaitReady(timeout); err != nil {
+					log.Println("Restart Server failed:", err)
+					continue
+				}
+			}
+		case <-app.done:
+			return app.Wait()
+		}
+		break
+	}
+
+	log.Println("Shutdown Server ...")
+	ctx, cancel := context.WithTimeout(context.Background(), web.DrainTimeout(app.config))
+	defer cancel()
+	if err := app.Stop(ctx); err != nil {
+		return err
+	}
+	err := app.Wait()
+	log.Println("Server exiting")
+	return err
+}
+
+func isRestartSignal(sig os.Signal) bool {
+	for _, s := range restartSignals {
+		if sig == s {
+			return true
+		}
+	}
+	return false
+}
+
+// loadConfig 优先从配置文件加载，否则使用传入的配置对象
+func loadConfig(configName, configType string, conf config.Config) (config.Config, error) {
+	//if !reflect.DeepEqual(g, Gowb{}) {
+	if configName != "" && configType != "" {
+		cu, err := utils.NewConfig(configName, configType)
+		if err != nil {
+			return conf, err
+		}
+		// 解析并处理yaml
+		var _config config.Config
+		if err := cu.Unmarshal(&_config); err != nil {
+			return conf, err
+		}
+		return _config, nil
+	} else if unsafe.Sizeof(conf) > 0 {
+		return conf, nil
+	}
+	return conf, errors.New("ConfigName and ConfigType is empty!")
+}
+
+func initMysql(c context.Context, tables []interface{}) error {
+	err := db.InitMysql(c)
+	if err != nil {
+		return err
+	}
+	//建表
+	for _, t := range tables {
+		db.DB.AutoMigrate(t)
+	}
+	return nil
+}
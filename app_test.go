@@ -0,0 +1,185 @@
+package gowb
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/db"
+)
+
+func testConfig() config.Config {
+	return config.Config{Log: config.Log{Level: "error"}, Web: config.Web{Host: "127.0.0.1", RunMode: "release"}}
+}
+
+// fakeRedis 对所有命令回复PONG，足以通过InitRedis的PING
+func fakeRedis(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line[0] == '*' {
+						conn.Write([]byte("+PONG\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func startWithTimeout(t *testing.T, a *App) error {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() { errc <- a.Start(context.Background()) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return")
+		return nil
+	}
+}
+
+func TestOnReadyCanReadAddr(t *testing.T) {
+	var a *App
+	var addr string
+	a, err := New(Gowb{Config: testConfig(), Hooks: Hooks{OnReady: []Hook{func(context.Context) error {
+		addr = a.Addr()
+		return nil
+	}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := startWithTimeout(t, a); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Stop(context.Background())
+	if _, port, _ := net.SplitHostPort(addr); port == "" || port == "0" {
+		t.Fatalf("OnReady saw addr %q", addr)
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	hookErr := errors.New("not ready")
+	conf := testConfig()
+	conf.Redis = config.Redis{Enabled: true, Addr: fakeRedis(t)}
+	a, _ := New(Gowb{Config: conf, Hooks: Hooks{OnReady: []Hook{func(context.Context) error { return hookErr }}}})
+	if err := startWithTimeout(t, a); err != hookErr {
+		t.Fatalf("Start = %v", err)
+	}
+	if err := db.Redis.Ping().Err(); err == nil {
+		t.Fatal("redis pool still open after failed Start")
+	}
+	done := make(chan error, 1)
+	go func() { done <- a.Wait() }()
+	select {
+	case err := <-done:
+		if err != hookErr {
+			t.Fatalf("Wait = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait blocked after failed Start")
+	}
+	if a.Addr() != "" {
+		t.Fatalf("Addr after failed Start = %q", a.Addr())
+	}
+}
+
+func TestWaitBeforeStart(t *testing.T) {
+	a, _ := New(Gowb{Config: testConfig()})
+	if err := a.Wait(); err == nil {
+		t.Fatal("Wait before Start should fail")
+	}
+}
+
+// 连接池是进程级的，第二个开启redis的App不能启动，否则停止一个会关闭另一个的连接
+func TestPoolsOwnedByOneApp(t *testing.T) {
+	conf := testConfig()
+	conf.Redis = config.Redis{Enabled: true, Addr: fakeRedis(t)}
+	first, _ := New(Gowb{Config: conf})
+	if err := startWithTimeout(t, first); err != nil {
+		t.Fatal(err)
+	}
+	second, _ := New(Gowb{Config: conf})
+	if err := startWithTimeout(t, second); err == nil {
+		second.Stop(context.Background())
+		t.Fatal("second App with redis started")
+	}
+	if err := db.Redis.Ping().Err(); err != nil {
+		t.Fatalf("first App's pool closed: %v", err)
+	}
+	// 不开启redis的App可以同时运行
+	plain, _ := New(Gowb{Config: testConfig()})
+	if err := startWithTimeout(t, plain); err != nil {
+		t.Fatal(err)
+	}
+	plain.Stop(context.Background())
+
+	first.Stop(context.Background())
+	third, _ := New(Gowb{Config: conf})
+	if err := startWithTimeout(t, third); err != nil {
+		t.Fatalf("pools not released by Stop: %v", err)
+	}
+	third.Stop(context.Background())
+}
+
+// blockingServer Shutdown阻塞到release关闭，返回err
+type blockingServer struct {
+	release chan struct{}
+	done    chan struct{}
+	err     error
+}
+
+func (s *blockingServer) Start() error { return nil }
+func (s *blockingServer) Shutdown(context.Context) error {
+	<-s.release
+	close(s.done)
+	return s.err
+}
+func (s *blockingServer) Addr() string          { return "" }
+func (s *blockingServer) Done() <-chan struct{} { return s.done }
+func (s *blockingServer) Err() error            { return nil }
+
+func TestConcurrentStop(t *testing.T) {
+	s := &blockingServer{release: make(chan struct{}), done: make(chan struct{}), err: errors.New("drain timeout")}
+	a, _ := New(Gowb{Config: testConfig()})
+	a.build = func(context.Context) (server, error) { return s, nil }
+	if err := startWithTimeout(t, a); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { results <- a.Stop(context.Background()) }()
+	}
+	select {
+	case err := <-results:
+		t.Fatalf("Stop returned %v while draining", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(s.release)
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != s.err {
+			t.Errorf("Stop = %v, want %v", err, s.err)
+		}
+	}
+}
@@ -1,18 +1,14 @@
 package gowb
 
 import (
-	"context"
-	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/cache"
 	"github.com/mj37yhyy/gowb/pkg/config"
-	"github.com/mj37yhyy/gowb/pkg/db"
-	gowbLog "github.com/mj37yhyy/gowb/pkg/log"
-	"github.com/mj37yhyy/gowb/pkg/utils"
+	"github.com/mj37yhyy/gowb/pkg/ratelimit"
 	"github.com/mj37yhyy/gowb/pkg/web"
 	"os"
 	"runtime"
-	"unsafe"
 )
 
 const logo = `
@@ -29,71 +25,25 @@ type Gowb struct {
 	ConfigType       string
 	Config           config.Config
 	Routers          []web.Router
+	Groups           []web.RouterGroup
 	AutoCreateTables []interface{}
 	Middleware       []gin.HandlerFunc
+	Hooks            Hooks
+	// 自定义的限流存储，默认按rateLimit.store选择
+	RateLimitStore ratelimit.Store
+	// 自定义的响应缓存存储，默认按cache.store选择
+	CacheStore cache.Store
 }
 
 func Bootstrap(g Gowb) (err error) {
-	fmt.Println(logo)
+	// logo以换行结尾，fmt.Println会被go vet（go test）报告为多余的换行
+	fmt.Printf("%s\n", logo)
 	if len(os.Getenv("GOMAXPROCS")) == 0 {
 		runtime.GOMAXPROCS(runtime.NumCPU())
 	}
-	//if !reflect.DeepEqual(g, Gowb{}) {
-	if g.ConfigName != "" && g.ConfigType != "" {
-		cu, err := utils.NewConfig(g.ConfigName, g.ConfigType)
-		if err != nil {
-			return err
-		}
-		// 解析并处理yaml
-		var _config config.Config
-		if err := cu.Unmarshal(&_config); err != nil {
-			return err
-		} else {
-			if err := doBootstrap(g, _config); err != nil {
-				return err
-			}
-		}
-	} else if unsafe.Sizeof(g.Config) > 0 {
-		if err := doBootstrap(g, g.Config); err != nil {
-			return err
-		}
-	} else {
-		return errors.New("ConfigName and ConfigType is empty!")
-	}
-	return nil
-}
-
-func doBootstrap(g Gowb, config config.Config) error {
-	c := context.WithValue(context.Background(), "routers", g.Routers)
-	c = context.WithValue(c, "config", config)
-	c = context.WithValue(c, "middleware", g.Middleware)
-	//初始化mysql
-	if config.Mysql.Enabled {
-		err := initMysql(c, g)
-		if err != nil {
-			return err
-		}
-	}
-
-	//初始化日志
-	err := gowbLog.InitLogger(c)
-	if err != nil {
-		return err
-	}
-
-	//初始化gin
-	web.Bootstrap(c)
-	return nil
-}
-
-func initMysql(c context.Context, g Gowb) error {
-	err := db.InitMysql(c)
+	app, err := New(g)
 	if err != nil {
 		return err
 	}
-	//建表
-	for _, t := range g.AutoCreateTables {
-		db.DB.AutoMigrate(t)
-	}
-	return nil
+	return run(app)
 }
@@ -5,14 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/signal"
 	"runtime"
-	"syscall"
-	"unsafe"
+	"sync"
 
 	"github.com/mj37yhyy/gowb/pkg/config"
-	"github.com/mj37yhyy/gowb/pkg/db"
-	gowbLog "github.com/mj37yhyy/gowb/pkg/log"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/listener"
 	"github.com/mj37yhyy/gowb/pkg/mcp"
 	"github.com/mj37yhyy/gowb/pkg/mcp/transport"
 	"github.com/mj37yhyy/gowb/pkg/utils"
@@ -37,60 +35,43 @@ type MCPOptions struct {
 	Config           config.Config            // 配置对象（可选）
 	Actions          map[string]mcp.ActionDef // Action定义
 	Transport        mcp.TransportType        // 传输类型：stdio或sse
-	SSEEndpoint      string                   // SSE模式下的监听地址，如":8081"
+	SSEEndpoint      string                   // SSE模式下的监听地址，如":8081"、"unix:///run/mcp.sock"、"fd://3"
+	SocketMode       string                   // SSE监听unix socket时的文件权限，如"0660"
+	TLS              config.TLS               // SSE模式下的TLS/mTLS配置
 	Auth             mcp.AuthConfig           // 认证配置
 	AutoCreateTables []interface{}            // 自动创建的数据库表
 	ExcludeActions   []string                 // 黑名单：不暴露的Action
 	IncludeActions   []string                 // 白名单：只暴露这些Action（如果设置）
+	Hooks            Hooks                    // 生命周期钩子
 }
 
 // BootstrapMCP 启动MCP服务器
 func BootstrapMCP(opts MCPOptions) error {
-	fmt.Println(mcpLogo)
+	// mcpLogo以换行结尾，fmt.Println会被go vet（go test）报告为多余的换行
+	fmt.Printf("%s\n", mcpLogo)
 
 	if len(os.Getenv("GOMAXPROCS")) == 0 {
 		runtime.GOMAXPROCS(runtime.NumCPU())
 	}
 
-	// 加载配置
-	var conf config.Config
-	if opts.ConfigName != "" && opts.ConfigType != "" {
-		cu, err := utils.NewConfig(opts.ConfigName, opts.ConfigType)
-		if err != nil {
-			return err
-		}
-		if err := cu.Unmarshal(&conf); err != nil {
-			return err
-		}
-	} else if unsafe.Sizeof(opts.Config) > 0 {
-		conf = opts.Config
-	} else {
-		return errors.New("ConfigName and ConfigType is empty!")
-	}
-
-	// 初始化上下文
-	ctx := context.Background()
-	ctx = context.WithValue(ctx, "config", conf)
-
-	// 初始化MySQL（如果配置了）
-	if conf.Mysql.Enabled {
-		if err := db.InitMysql(ctx); err != nil {
-			return err
-		}
-		// 自动建表
-		for _, t := range opts.AutoCreateTables {
-			db.DB.AutoMigrate(t)
-		}
+	app, err := NewMCP(opts)
+	if err != nil {
+		return err
 	}
+	return run(app)
+}
 
-	// 初始化日志
-	if err := gowbLog.InitLogger(ctx); err != nil {
-		return err
+// NewMCP 创建MCP应用，不会启动服务
+func NewMCP(opts MCPOptions) (*App, error) {
+	// 加载配置
+	conf, err := loadConfig(opts.ConfigName, opts.ConfigType, opts.Config)
+	if err != nil {
+		return nil, err
 	}
 
 	// 验证Actions
 	if opts.Actions == nil || len(opts.Actions) == 0 {
-		return errors.New("Actions cannot be empty")
+		return nil, errors.New("Actions cannot be empty")
 	}
 
 	// 设置默认值
@@ -106,51 +87,97 @@ func BootstrapMCP(opts MCPOptions) error {
 	if opts.Transport == mcp.TransportSSE && opts.SSEEndpoint == "" {
 		opts.SSEEndpoint = ":8081"
 	}
+	if opts.Transport != mcp.TransportStdio && opts.Transport != mcp.TransportSSE {
+		return nil, fmt.Errorf("unsupported transport type: %s", opts.Transport)
+	}
 
+	// 初始化上下文
+	ctx := ctxkit.WithConfig(context.Background(), conf)
+
+	return &App{
+		config:           conf,
+		values:           ctx,
+		autoCreateTables: opts.AutoCreateTables,
+		hooks:            opts.Hooks,
+		build: func(c context.Context) (server, error) {
+			return newMCPServer(opts, conf)
+		},
+		done: make(chan struct{}),
+	}, nil
+}
+
+// newMCPServer 创建MCP服务器并根据传输类型包装
+func newMCPServer(opts MCPOptions, conf config.Config) (server, error) {
 	// 创建MCP服务器
-	server := mcp.NewServer(opts.Name, opts.Version, opts.Description, opts.Actions, &opts.Auth, conf)
+	s := mcp.NewServer(opts.Name, opts.Version, opts.Description, opts.Actions, &opts.Auth, conf)
 
 	// 设置过滤规则
 	if len(opts.ExcludeActions) > 0 {
-		server.SetExcludes(opts.ExcludeActions)
+		s.SetExcludes(opts.ExcludeActions)
 	}
 	if len(opts.IncludeActions) > 0 {
-		server.SetIncludes(opts.IncludeActions)
+		s.SetIncludes(opts.IncludeActions)
 	}
 
 	// 从环境变量加载认证信息
-	server.LoadAuthFromEnv()
-
-	// 根据传输类型启动
-	switch opts.Transport {
-	case mcp.TransportStdio:
-		return startStdioTransport(server)
-	case mcp.TransportSSE:
-		return startSSETransport(server, opts.SSEEndpoint)
-	default:
-		return fmt.Errorf("unsupported transport type: %s", opts.Transport)
+	s.LoadAuthFromEnv()
+
+	if opts.Transport == mcp.TransportStdio {
+		return &stdioServer{t: transport.NewStdioTransport(s), done: make(chan struct{})}, nil
+	}
+	t := transport.NewSSETransport(s, opts.SSEEndpoint)
+	socketMode, err := listener.ParseMode(opts.SocketMode)
+	if err != nil {
+		return nil, err
+	}
+	t.SetListenOptions(listener.Options{SocketMode: socketMode})
+	t.SetCORS(conf.Web.CORS)
+	if opts.TLS.Enabled {
+		tlsConfig, err := utils.NewTLSConfig(opts.TLS)
+		if err != nil {
+			return nil, err
+		}
+		t.SetTLS(tlsConfig)
 	}
+	return t, nil
 }
 
-// startStdioTransport 启动stdio传输
-func startStdioTransport(server *mcp.Server) error {
-	t := transport.NewStdioTransport(server)
-	return t.Start()
+// stdioServer 将阻塞的stdio传输包装为可后台运行的服务
+type stdioServer struct {
+	t    *transport.StdioTransport
+	once sync.Once
+	done chan struct{}
+	err  error
 }
 
-// startSSETransport 启动SSE传输
-func startSSETransport(server *mcp.Server, endpoint string) error {
-	t := transport.NewSSETransport(server, endpoint)
-	if err := t.Start(); err != nil {
-		return err
-	}
+func (s *stdioServer) Start() error {
+	go func() {
+		s.finish(s.t.Start())
+	}()
+	return nil
+}
+
+// Shutdown stdin上阻塞的读取无法中断，直接标记服务结束
+func (s *stdioServer) Shutdown(ctx context.Context) error {
+	s.finish(s.t.Stop())
+	return nil
+}
+
+func (s *stdioServer) Addr() string {
+	return string(mcp.TransportStdio)
+}
+
+func (s *stdioServer) Done() <-chan struct{} {
+	return s.done
+}
 
-	// 阻塞等待信号（复用web包的信号处理）
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	fmt.Println("Shutdown MCP Server...")
+func (s *stdioServer) Err() error {
+	return s.err
+}
 
-	// 优雅关闭
-	return t.Stop()
+func (s *stdioServer) finish(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+	})
 }
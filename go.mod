@@ -3,14 +3,22 @@ module github.com/mj37yhyy/gowb
 go 1.13
 
 require (
+	github.com/andybalholm/brotli v1.0.4
 	github.com/chenjiandongx/ginprom v0.0.0-20191227144730-e11ebf56bc05
+	github.com/gin-contrib/sse v0.1.0
 	github.com/gin-gonic/gin v1.5.0
+	github.com/go-redis/redis/v7 v7.4.1
+	github.com/golang/protobuf v1.3.2
+	github.com/gorilla/websocket v1.4.2
 	github.com/jinzhu/gorm v1.9.12
 	github.com/mitchellh/mapstructure v1.1.2
 	github.com/prometheus/client_golang v1.5.1
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/viper v1.6.2
 	github.com/swaggo/gin-swagger v1.2.0
+	github.com/ugorji/go/codec v1.1.7
 	github.com/willf/pad v0.0.0-20200313202418-172aa767f2a4
 	github.com/xiaolin8/lager v0.0.0-20191218124133-d87657fbc6c6
+	gopkg.in/go-playground/validator.v9 v9.29.1
+	gopkg.in/yaml.v2 v2.2.7
 )
@@ -0,0 +1,41 @@
+package gowb
+
+import (
+	"context"
+	"log"
+)
+
+// Hook 生命周期钩子，返回错误会中止启动
+type Hook func(ctx context.Context) error
+
+// Hooks 应用生命周期钩子，同一阶段按注册顺序执行
+type Hooks struct {
+	BeforeInit []Hook // mysql、日志初始化之前
+	AfterInit  []Hook // 初始化完成、服务启动之前
+	OnReady    []Hook // 监听地址绑定之后
+	OnShutdown []Hook // 服务停止之后、资源释放之前，逆序执行并共享Stop的截止时间
+}
+
+// runHooks 顺序执行钩子，遇到错误立即返回
+func runHooks(ctx context.Context, hooks []Hook) error {
+	for _, h := range hooks {
+		if err := h(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runShutdownHooks 逆序执行全部关闭钩子，返回第一个错误
+func runShutdownHooks(ctx context.Context, hooks []Hook) error {
+	var first error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			log.Printf("[error] shutdown hook: %s", err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
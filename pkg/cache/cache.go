@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cache 按名称分组的缓存。失效时更新名称的版本号，旧版本的条目不再被读取，等待过期或淘汰，
+// 因此共享存储不需要按前缀扫描删除。进程内存储（LRUStore）的版本号保存在Cache中，
+// 以免被淘汰后旧版本的条目重新生效
+type Cache struct {
+	store  Store
+	prefix string
+
+	mu       sync.Mutex
+	calls    map[string]*call
+	versions map[string][]byte
+}
+
+// call 进行中的加载，相同key的并发请求等待同一次加载的结果，加载结束时关闭done。
+// load发生panic时等待者得到cacheable为false，panic在执行加载的goroutine中继续抛出
+type call struct {
+	done      chan struct{}
+	value     []byte
+	cacheable bool
+}
+
+// New prefix为所有key的前缀，默认"gowb:cache:"
+func New(store Store, prefix string) *Cache {
+	if prefix == "" {
+		prefix = "gowb:cache:"
+	}
+	return &Cache{store: store, prefix: prefix, calls: make(map[string]*call), versions: make(map[string][]byte)}
+}
+
+/*
+读取name下的key，未命中时调用load。并发的相同请求只有一个执行load，其余等待其结果；
+load返回cacheable为true时保存结果，否则等待者的ok为false，须自行处理。
+err为存储的错误，此时仍会执行load并返回其结果；等待期间ctx结束时返回ctx.Err()，不影响进行中的加载
+*/
+func (c *Cache) Load(ctx context.Context, name, key string, ttl time.Duration, load func() (value []byte, cacheable bool)) (value []byte, ok bool, err error) {
+	// 读取与保存使用同一个版本号，加载期间发生的失效不会被新结果覆盖
+	version, err := c.version(ctx, name)
+	full := c.prefix + name + ":" + string(version) + ":" + key
+	if err == nil {
+		var hit bool
+		if value, hit, err = c.store.Get(ctx, full); err == nil && hit {
+			return value, true, nil
+		}
+	}
+
+	cl, leader := c.join(full)
+	if !leader {
+		select {
+		case <-cl.done:
+			return cl.value, cl.cacheable, err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+	defer c.finish(full, cl)
+	cl.value, cl.cacheable = load()
+	if cl.cacheable && err == nil {
+		err = c.store.Set(ctx, full, cl.value, ttl)
+	}
+	return cl.value, cl.cacheable, err
+}
+
+// Invalidate 使name下的所有条目失效，c为nil时不做任何事
+func (c *Cache) Invalidate(ctx context.Context, names ...string) error {
+	if c == nil {
+		return nil
+	}
+	for _, name := range names {
+		version := []byte(strconv.FormatInt(time.Now().UnixNano(), 36))
+		if _, ok := c.store.(localStore); ok {
+			c.mu.Lock()
+			c.versions[name] = version
+			c.mu.Unlock()
+			continue
+		}
+		if err := c.store.Set(ctx, c.prefix+"version:"+name, version, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cache) version(ctx context.Context, name string) ([]byte, error) {
+	if _, ok := c.store.(localStore); ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.versions[name], nil
+	}
+	version, _, err := c.store.Get(ctx, c.prefix+"version:"+name)
+	return version, err
+}
+
+func (c *Cache) join(key string) (*call, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cl, ok := c.calls[key]; ok {
+		return cl, false
+	}
+	cl := &call{done: make(chan struct{})}
+	c.calls[key] = cl
+	return cl, true
+}
+
+func (c *Cache) finish(key string, cl *call) {
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+	close(cl.done)
+}
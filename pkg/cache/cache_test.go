@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	c := New(NewLRUStore(0), "")
+	ctx := context.Background()
+	var loads int32
+	load := func(value string, cacheable bool) func() ([]byte, bool) {
+		return func() ([]byte, bool) {
+			atomic.AddInt32(&loads, 1)
+			return []byte(value), cacheable
+		}
+	}
+
+	steps := []struct {
+		name       string
+		invalidate bool
+		value      string
+		cacheable  bool
+		want       string
+		ok         bool
+		loads      int32
+	}{
+		{"miss not cacheable", false, "a", false, "a", false, 1},
+		{"miss", false, "b", true, "b", true, 2},
+		{"hit", false, "c", true, "b", true, 2},
+		{"invalidated", true, "d", true, "d", true, 3},
+		{"hit after invalidate", false, "e", true, "d", true, 3},
+	}
+	for _, s := range steps {
+		if s.invalidate {
+			if err := c.Invalidate(ctx, "users"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		value, ok, err := c.Load(ctx, "users", "k", 0, load(s.value, s.cacheable))
+		if err != nil || string(value) != s.want || ok != s.ok || atomic.LoadInt32(&loads) != s.loads {
+			t.Errorf("%s: got %q %v %v, loads %d", s.name, value, ok, err, loads)
+		}
+	}
+	if err := (*Cache)(nil).Invalidate(ctx, "users"); err != nil {
+		t.Errorf("nil cache: %v", err)
+	}
+}
+
+func TestLoadSingleflight(t *testing.T) {
+	c := New(NewLRUStore(0), "")
+	release := make(chan struct{})
+	var loads int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, ok, err := c.Load(context.Background(), "n", "k", 0, func() ([]byte, bool) {
+				atomic.AddInt32(&loads, 1)
+				<-release
+				return []byte("v"), true
+			})
+			if string(value) != "v" || !ok || err != nil {
+				t.Errorf("got %q %v %v", value, ok, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if loads != 1 {
+		t.Errorf("loads = %d, want 1", loads)
+	}
+}
+
+// 等待中的请求在自己的ctx结束时返回，不等待进行中的加载
+func TestLoadWaiterCancel(t *testing.T) {
+	c := New(NewLRUStore(0), "")
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	go c.Load(context.Background(), "n", "k", 0, func() ([]byte, bool) {
+		close(started)
+		<-release
+		return []byte("v"), true
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, ok, err := c.Load(ctx, "n", "k", 0, func() ([]byte, bool) {
+			t.Error("waiter must not load")
+			return nil, false
+		})
+		if ok {
+			t.Error("cancelled waiter got ok")
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Errorf("err = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter blocked after its context ended")
+	}
+}
+
+// 进程内存储淘汰条目后，失效前的条目不会重新生效
+func TestInvalidateSurvivesEviction(t *testing.T) {
+	store := NewLRUStore(2)
+	c := New(store, "")
+	ctx := context.Background()
+	load := func(value string) func() ([]byte, bool) {
+		return func() ([]byte, bool) { return []byte(value), true }
+	}
+	if _, _, err := c.Load(ctx, "users", "k", 0, load("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Invalidate(ctx, "users"); err != nil {
+		t.Fatal(err)
+	}
+	// 访问旧条目并写入其它条目，使最久未使用的条目被淘汰
+	store.Get(ctx, "gowb:cache:users::k")
+	store.Set(ctx, "other", []byte("x"), 0)
+	if value, _, _ := c.Load(ctx, "users", "k", 0, load("new")); string(value) != "new" {
+		t.Errorf("got %q after eviction", value)
+	}
+}
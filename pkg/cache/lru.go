@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRUStore 进程内的LRU存储，超过最大条目数时淘汰最久未使用的条目
+type LRUStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type lruEntry struct {
+	key    string
+	value  []byte
+	expire time.Time
+}
+
+// NewLRUStore maxEntries为最大条目数，默认10000
+func NewLRUStore(maxEntries int) *LRUStore {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	return &LRUStore{maxEntries: maxEntries, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Get 实现Store
+func (s *LRUStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*lruEntry)
+	if !e.expire.IsZero() && time.Now().After(e.expire) {
+		s.remove(el)
+		return nil, false, nil
+	}
+	s.ll.MoveToFront(el)
+	return e.value, true, nil
+}
+
+// Set 实现Store
+func (s *LRUStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	var expire time.Time
+	if ttl > 0 {
+		expire = time.Now().Add(ttl)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value, e.expire = value, expire
+		s.ll.MoveToFront(el)
+		return nil
+	}
+	s.items[key] = s.ll.PushFront(&lruEntry{key: key, value: value, expire: expire})
+	for s.ll.Len() > s.maxEntries {
+		s.remove(s.ll.Back())
+	}
+	return nil
+}
+
+// Delete 实现Store
+func (s *LRUStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// Len 当前的条目数
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+// local 实现localStore
+func (s *LRUStore) local() {}
+
+func (s *LRUStore) remove(el *list.Element) {
+	s.ll.Remove(el)
+	delete(s.items, el.Value.(*lruEntry).key)
+}
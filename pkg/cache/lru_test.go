@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewLRUStore(2)
+	type op struct {
+		kind  string // set、get、del、sleep
+		key   string
+		ttl   time.Duration
+		value string
+		hit   bool
+	}
+	ops := []op{
+		{kind: "set", key: "a", value: "1"},
+		{kind: "set", key: "b", value: "2"},
+		{kind: "get", key: "a", value: "1", hit: true},
+		// b最久未使用，被淘汰
+		{kind: "set", key: "c", value: "3"},
+		{kind: "get", key: "b"},
+		{kind: "get", key: "a", value: "1", hit: true},
+		{kind: "set", key: "a", value: "4"},
+		{kind: "get", key: "a", value: "4", hit: true},
+		{kind: "del", key: "a"},
+		{kind: "get", key: "a"},
+		{kind: "set", key: "d", value: "5", ttl: 10 * time.Millisecond},
+		{kind: "get", key: "d", value: "5", hit: true},
+		{kind: "sleep", ttl: 20 * time.Millisecond},
+		{kind: "get", key: "d"},
+		{kind: "get", key: "c", value: "3", hit: true},
+	}
+	for i, o := range ops {
+		switch o.kind {
+		case "set":
+			s.Set(ctx, o.key, []byte(o.value), o.ttl)
+		case "del":
+			s.Delete(ctx, o.key)
+		case "sleep":
+			time.Sleep(o.ttl)
+		case "get":
+			value, hit, err := s.Get(ctx, o.key)
+			if err != nil || hit != o.hit || string(value) != o.value {
+				t.Errorf("op %d get %s: got %q %v %v", i, o.key, value, hit, err)
+			}
+		}
+		if s.Len() > 2 {
+			t.Errorf("op %d: %d entries", i, s.Len())
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+// RedisStore 基于redis的共享存储
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) with(ctx context.Context) redis.UniversalClient {
+	if c, ok := s.client.(*redis.Client); ok {
+		return c.WithContext(ctx)
+	}
+	return s.client
+}
+
+// Get 实现Store
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.with(ctx).Get(key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set 实现Store
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.with(ctx).Set(key, value, ttl).Err()
+}
+
+// Delete 实现Store
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	return s.with(ctx).Del(key).Err()
+}
@@ -0,0 +1,20 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store 缓存的存储，多实例部署时使用共享的存储（如RedisStore）可以在实例间共享缓存与失效
+type Store interface {
+	// Get 返回key对应的值，不存在或已过期时ok为false
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set 保存值，ttl为0时不过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// localStore 进程内会淘汰条目的存储，Cache将其版本号保存在自身中
+type localStore interface {
+	local()
+}
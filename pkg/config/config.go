@@ -7,6 +7,7 @@ type Config struct {
 	Web   Web   `mapstructure:"web" yaml:"web" json:"web"`
 	Trace Trace `mapstructure:"trace" yaml:"trace" json:"trace"`
 	Mysql Mysql `mapstructure:"mysql" yaml:"mysql" json:"mysql"`
+	Redis Redis `mapstructure:"redis" yaml:"redis" json:"redis"`
 }
 
 type Fields struct {
@@ -23,10 +24,213 @@ type Log struct {
 }
 
 type Web struct {
-	Port        int      `mapstructure:"port" yaml:"port" json:"port"`
-	RunMode     string   `mapstructure:"runMode" yaml:"runMode" json:"runMode"`
-	LogSkipPath []string `mapstructure:"logSkipPath" yaml:"logSkipPath" json:"logSkipPath"`
-	DisableRequestLogMiddleware bool `mapstructure:"disableRequestLogMiddleware" yaml:"disableRequestLogMiddleware" json:"disableRequestLogMiddleware"`
+	Port                        int      `mapstructure:"port" yaml:"port" json:"port"`
+	RunMode                     string   `mapstructure:"runMode" yaml:"runMode" json:"runMode"`
+	LogSkipPath                 []string `mapstructure:"logSkipPath" yaml:"logSkipPath" json:"logSkipPath"`
+	DisableRequestLogMiddleware bool     `mapstructure:"disableRequestLogMiddleware" yaml:"disableRequestLogMiddleware" json:"disableRequestLogMiddleware"`
+
+	// 监听的网卡地址，默认所有网卡
+	Host string `mapstructure:"host" yaml:"host" json:"host"`
+	// 监听地址，设置后忽略host与port，支持tcp://host:port、unix:///path、fd://3
+	Listen string `mapstructure:"listen" yaml:"listen" json:"listen"`
+	// unix socket文件权限，八进制，如"0660"
+	SocketMode string `mapstructure:"socketMode" yaml:"socketMode" json:"socketMode"`
+
+	// 以下超时单位均为秒，未设置时读写超时默认60秒，其余不限制
+	ReadTimeout       time.Duration `mapstructure:"readTimeout" yaml:"readTimeout" json:"readTimeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"readHeaderTimeout" yaml:"readHeaderTimeout" json:"readHeaderTimeout"`
+	WriteTimeout      time.Duration `mapstructure:"writeTimeout" yaml:"writeTimeout" json:"writeTimeout"`
+	IdleTimeout       time.Duration `mapstructure:"idleTimeout" yaml:"idleTimeout" json:"idleTimeout"`
+	// 请求头最大字节数，默认1MB
+	MaxHeaderBytes int `mapstructure:"maxHeaderBytes" yaml:"maxHeaderBytes" json:"maxHeaderBytes"`
+	// 请求体最大字节数，超过时返回413，0不限制，可被路由的MaxBodySize覆盖
+	MaxBodySize int64 `mapstructure:"maxBodySize" yaml:"maxBodySize" json:"maxBodySize"`
+	// multipart上传在内存中保留的最大字节数，超过的部分写入临时文件，默认32MB
+	MultipartMemory int64 `mapstructure:"multipartMemory" yaml:"multipartMemory" json:"multipartMemory"`
+	// 关闭HTTP keep-alive，每个请求结束后断开连接
+	DisableKeepAlives bool `mapstructure:"disableKeepAlives" yaml:"disableKeepAlives" json:"disableKeepAlives"`
+	// TCP keep-alive探测间隔，单位秒，0使用系统默认值，负数关闭
+	TCPKeepAlive time.Duration `mapstructure:"tcpKeepAlive" yaml:"tcpKeepAlive" json:"tcpKeepAlive"`
+
+	// 停机时/health返回未就绪后等待负载均衡摘流的时间，单位秒
+	PreStopDelay time.Duration `mapstructure:"preStopDelay" yaml:"preStopDelay" json:"preStopDelay"`
+	// 排空请求、执行关闭钩子与释放资源的最长时间，单位秒，默认5秒
+	ShutdownTimeout time.Duration `mapstructure:"shutdownTimeout" yaml:"shutdownTimeout" json:"shutdownTimeout"`
+	// 收到SIGHUP或SIGUSR2时启动新进程并交接监听socket，旧进程排空后退出
+	GracefulRestart bool `mapstructure:"gracefulRestart" yaml:"gracefulRestart" json:"gracefulRestart"`
+	// 热重启时等待新进程就绪的最长时间，单位秒，默认30秒，超时后结束新进程并继续服务
+	RestartTimeout time.Duration `mapstructure:"restartTimeout" yaml:"restartTimeout" json:"restartTimeout"`
+
+	TLS TLS `mapstructure:"tls" yaml:"tls" json:"tls"`
+
+	// 声明式反向代理路由
+	Proxies []Proxy `mapstructure:"proxies" yaml:"proxies" json:"proxies"`
+
+	RateLimit RateLimit `mapstructure:"rateLimit" yaml:"rateLimit" json:"rateLimit"`
+
+	CORS CORS `mapstructure:"cors" yaml:"cors" json:"cors"`
+
+	Security Security `mapstructure:"security" yaml:"security" json:"security"`
+
+	Cache Cache `mapstructure:"cache" yaml:"cache" json:"cache"`
+
+	Compression Compression `mapstructure:"compression" yaml:"compression" json:"compression"`
+}
+
+// Compression 响应压缩，按Accept-Encoding选择br、gzip或deflate
+type Compression struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled" json:"enabled"`
+	// 压缩级别1-9，默认6
+	Level int `mapstructure:"level" yaml:"level" json:"level"`
+	// 小于该字节数的响应不压缩，默认1024，负数时压缩所有响应
+	MinSize int `mapstructure:"minSize" yaml:"minSize" json:"minSize"`
+	// 可压缩的媒体类型，以/*结尾时按前缀匹配，默认为JSON、XML、NDJSON、JavaScript、SVG与text/*
+	ContentTypes []string `mapstructure:"contentTypes" yaml:"contentTypes" json:"contentTypes"`
+}
+
+// Cache 服务端响应缓存
+type Cache struct {
+	// 存储：memory（默认，进程内LRU）、redis（多实例共享，需开启redis）
+	Store string `mapstructure:"store" yaml:"store" json:"store"`
+	// memory存储的最大条目数，默认10000
+	MaxEntries int `mapstructure:"maxEntries" yaml:"maxEntries" json:"maxEntries"`
+}
+
+// Security 安全响应头，未设置的字段使用默认值，设置为"-"时不输出该响应头
+type Security struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled" json:"enabled"`
+	// Content-Security-Policy，键为指令，值为来源，如default-src: ["'self'"]
+	CSP map[string][]string `mapstructure:"csp" yaml:"csp" json:"csp"`
+	// 只报告不拦截，输出Content-Security-Policy-Report-Only
+	CSPReportOnly bool `mapstructure:"cspReportOnly" yaml:"cspReportOnly" json:"cspReportOnly"`
+	// 接收CSP违规报告的路径，设置后注册该路由记录日志，并加入report-uri指令
+	CSPReportPath string `mapstructure:"cspReportPath" yaml:"cspReportPath" json:"cspReportPath"`
+	HSTS          HSTS   `mapstructure:"hsts" yaml:"hsts" json:"hsts"`
+	// X-Frame-Options，默认DENY
+	FrameOptions string `mapstructure:"frameOptions" yaml:"frameOptions" json:"frameOptions"`
+	// X-Content-Type-Options，默认nosniff
+	ContentTypeOptions string `mapstructure:"contentTypeOptions" yaml:"contentTypeOptions" json:"contentTypeOptions"`
+	// Referrer-Policy，默认strict-origin-when-cross-origin
+	ReferrerPolicy string `mapstructure:"referrerPolicy" yaml:"referrerPolicy" json:"referrerPolicy"`
+	// Permissions-Policy，键为特性，值为允许的来源，空列表表示禁用，如camera: []、geolocation: ["self"]
+	PermissionsPolicy map[string][]string `mapstructure:"permissionsPolicy" yaml:"permissionsPolicy" json:"permissionsPolicy"`
+	// Cross-Origin-Opener-Policy与Cross-Origin-Embedder-Policy，默认不输出
+	CrossOriginOpenerPolicy   string `mapstructure:"crossOriginOpenerPolicy" yaml:"crossOriginOpenerPolicy" json:"crossOriginOpenerPolicy"`
+	CrossOriginEmbedderPolicy string `mapstructure:"crossOriginEmbedderPolicy" yaml:"crossOriginEmbedderPolicy" json:"crossOriginEmbedderPolicy"`
+}
+
+// HSTS Strict-Transport-Security，仅对HTTPS请求（包括X-Forwarded-Proto为https）输出
+type HSTS struct {
+	Disabled bool `mapstructure:"disabled" yaml:"disabled" json:"disabled"`
+	// 单位秒，默认一年
+	MaxAge            time.Duration `mapstructure:"maxAge" yaml:"maxAge" json:"maxAge"`
+	IncludeSubDomains bool          `mapstructure:"includeSubDomains" yaml:"includeSubDomains" json:"includeSubDomains"`
+	Preload           bool          `mapstructure:"preload" yaml:"preload" json:"preload"`
+}
+
+// CORS 跨域策略，未配置时允许所有来源
+type CORS struct {
+	// 关闭跨域处理，OPTIONS请求交给路由处理
+	Disabled bool `mapstructure:"disabled" yaml:"disabled" json:"disabled"`
+	// 允许的来源：*、精确匹配（https://a.com）、通配子域名（https://*.a.com）、以~开头的正则表达式
+	AllowOrigins []string `mapstructure:"allowOrigins" yaml:"allowOrigins" json:"allowOrigins"`
+	// 允许的方法，默认GET、POST、PUT、PATCH、DELETE、HEAD、OPTIONS
+	AllowMethods []string `mapstructure:"allowMethods" yaml:"allowMethods" json:"allowMethods"`
+	// 允许的请求头，默认authorization、origin、content-type、accept，*表示允许预检请求声明的所有请求头
+	AllowHeaders []string `mapstructure:"allowHeaders" yaml:"allowHeaders" json:"allowHeaders"`
+	// 浏览器可以读取的响应头
+	ExposeHeaders []string `mapstructure:"exposeHeaders" yaml:"exposeHeaders" json:"exposeHeaders"`
+	// 允许携带cookie等凭证，此时*来源返回请求的Origin
+	AllowCredentials bool `mapstructure:"allowCredentials" yaml:"allowCredentials" json:"allowCredentials"`
+	// 预检结果的缓存时间，单位秒，0不设置
+	MaxAge time.Duration `mapstructure:"maxAge" yaml:"maxAge" json:"maxAge"`
+}
+
+// RateLimit 限流
+type RateLimit struct {
+	// 计数的存储：memory（默认，单实例）、redis（多实例共享，需开启redis）
+	Store string `mapstructure:"store" yaml:"store" json:"store"`
+	// 限流规则，按顺序匹配第一条
+	Rules []RateLimitRule `mapstructure:"rules" yaml:"rules" json:"rules"`
+}
+
+// RateLimitRule 限流规则
+type RateLimitRule struct {
+	// 规则名称，不同规则的计数相互独立，默认使用method与path
+	Name string `mapstructure:"name" yaml:"name" json:"name"`
+	// 请求路径，以*结尾时按前缀匹配，为空时匹配所有路径
+	Path string `mapstructure:"path" yaml:"path" json:"path"`
+	// 请求方法，为空时匹配所有方法
+	Method string `mapstructure:"method" yaml:"method" json:"method"`
+	// 计数的键：ip（默认）、header:名称、field:日志字段名，取不到键的请求不限流
+	Key string `mapstructure:"key" yaml:"key" json:"key"`
+	// 算法：tokenBucket（默认）、slidingWindow
+	Algorithm string `mapstructure:"algorithm" yaml:"algorithm" json:"algorithm"`
+	// window秒内允许的请求数，window默认1秒
+	Limit  int           `mapstructure:"limit" yaml:"limit" json:"limit"`
+	Window time.Duration `mapstructure:"window" yaml:"window" json:"window"`
+	// 令牌桶容量，即允许的突发请求数，默认等于limit，仅对tokenBucket有效
+	Burst int `mapstructure:"burst" yaml:"burst" json:"burst"`
+}
+
+// Proxy 反向代理路由
+type Proxy struct {
+	// 路由路径，如/api/users/*path
+	Path string `mapstructure:"path" yaml:"path" json:"path"`
+	// 请求方法，为空时代理所有方法
+	Method string `mapstructure:"method" yaml:"method" json:"method"`
+	// 转发前从路径中去掉的前缀
+	StripPrefix string   `mapstructure:"stripPrefix" yaml:"stripPrefix" json:"stripPrefix"`
+	Upstream    Upstream `mapstructure:"upstream" yaml:"upstream" json:"upstream"`
+}
+
+// Upstream 上游服务池
+type Upstream struct {
+	// 上游地址，如http://10.0.0.1:8080
+	Targets []string `mapstructure:"targets" yaml:"targets" json:"targets"`
+	// 负载均衡策略：roundRobin（默认）、leastConn、consistentHash
+	Balance string `mapstructure:"balance" yaml:"balance" json:"balance"`
+	// consistentHash的键：ip（默认）、path、header:名称、query:名称、cookie:名称
+	HashKey string `mapstructure:"hashKey" yaml:"hashKey" json:"hashKey"`
+	// 幂等请求（GET、HEAD、OPTIONS、PUT、DELETE）在连接失败或返回502/503/504时换一个上游重试的次数，
+	// 请求体超过1MB的请求不重试
+	Retries int `mapstructure:"retries" yaml:"retries" json:"retries"`
+
+	// 以下超时单位均为秒，未设置时建连超时默认10秒、空闲连接超时默认90秒，等待响应头不限制
+	DialTimeout     time.Duration `mapstructure:"dialTimeout" yaml:"dialTimeout" json:"dialTimeout"`
+	ResponseTimeout time.Duration `mapstructure:"responseTimeout" yaml:"responseTimeout" json:"responseTimeout"`
+	IdleConnTimeout time.Duration `mapstructure:"idleConnTimeout" yaml:"idleConnTimeout" json:"idleConnTimeout"`
+	// 连接池：每个上游最多保持的空闲连接数（默认32）与最大连接数（默认不限制）
+	MaxIdleConnsPerHost int `mapstructure:"maxIdleConnsPerHost" yaml:"maxIdleConnsPerHost" json:"maxIdleConnsPerHost"`
+	MaxConnsPerHost     int `mapstructure:"maxConnsPerHost" yaml:"maxConnsPerHost" json:"maxConnsPerHost"`
+
+	HealthCheck HealthCheck `mapstructure:"healthCheck" yaml:"healthCheck" json:"healthCheck"`
+}
+
+// HealthCheck 上游健康检查
+type HealthCheck struct {
+	// 主动检查的路径，为空时不做主动检查，返回2xx、3xx视为健康
+	Path string `mapstructure:"path" yaml:"path" json:"path"`
+	// 主动检查的间隔与超时，单位秒，默认10秒与2秒
+	Interval time.Duration `mapstructure:"interval" yaml:"interval" json:"interval"`
+	Timeout  time.Duration `mapstructure:"timeout" yaml:"timeout" json:"timeout"`
+	// 被动检查：连续失败maxFails次（默认3，负数关闭）后摘除，failTimeout秒（默认10秒）后恢复
+	MaxFails    int           `mapstructure:"maxFails" yaml:"maxFails" json:"maxFails"`
+	FailTimeout time.Duration `mapstructure:"failTimeout" yaml:"failTimeout" json:"failTimeout"`
+}
+
+type TLS struct {
+	Enabled  bool   `mapstructure:"enabled" yaml:"enabled" json:"enabled"`
+	CertFile string `mapstructure:"certFile" yaml:"certFile" json:"certFile"`
+	KeyFile  string `mapstructure:"keyFile" yaml:"keyFile" json:"keyFile"`
+	// 设置后开启mTLS，校验客户端证书
+	ClientCAFile string `mapstructure:"clientCAFile" yaml:"clientCAFile" json:"clientCAFile"`
+	// require（默认）要求客户端必须提供证书，optional仅在提供时校验
+	ClientAuth string `mapstructure:"clientAuth" yaml:"clientAuth" json:"clientAuth"`
+	// 1.0、1.1、1.2、1.3，默认1.2
+	MinVersion string `mapstructure:"minVersion" yaml:"minVersion" json:"minVersion"`
+	// 检查证书文件变化的间隔，单位秒，默认10秒
+	ReloadInterval time.Duration `mapstructure:"reloadInterval" yaml:"reloadInterval" json:"reloadInterval"`
 }
 
 type Trace struct {
@@ -45,3 +249,16 @@ type Mysql struct {
 	MaxIdleConns    int           `mapstructure:"maxIdleConns" yaml:"maxIdleConns" json:"maxIdleConns"`
 	ConnMaxLifetime time.Duration `mapstructure:"connMaxLifetime" yaml:"connMaxLifetime" json:"connMaxLifetime"`
 }
+
+type Redis struct {
+	Enabled  bool   `mapstructure:"enabled" yaml:"enabled" json:"enabled"`
+	Addr     string `mapstructure:"addr" yaml:"addr" json:"addr"`
+	Password string `mapstructure:"password" yaml:"password" json:"password"`
+	DB       int    `mapstructure:"db" yaml:"db" json:"db"`
+	// 连接池大小，默认每个CPU 10个连接
+	PoolSize int `mapstructure:"poolSize" yaml:"poolSize" json:"poolSize"`
+	// 以下超时单位均为秒，未设置时建连超时默认5秒、读写超时默认3秒
+	DialTimeout  time.Duration `mapstructure:"dialTimeout" yaml:"dialTimeout" json:"dialTimeout"`
+	ReadTimeout  time.Duration `mapstructure:"readTimeout" yaml:"readTimeout" json:"readTimeout"`
+	WriteTimeout time.Duration `mapstructure:"writeTimeout" yaml:"writeTimeout" json:"writeTimeout"`
+}
@@ -13,9 +13,14 @@ const (
 	X_B3_FLAGS        = "X-B3-FLAGS"
 	X_OT_SPAN_CONTEXT = "X-OT-SPAN-CONTEXT"
 
+	// gin.Context中保存上下文与响应的键
+	ContextKey  = "context"
+	ResponseKey = "response"
+
+	// Deprecated: 字符串键容易与其他库冲突，请使用ctxkit包中的访问函数。
+	// 弃用期内框架仍同时写入以下键，之后的版本将不再写入
 	ConfigKey      = "config"
 	RoutersKey     = "routers"
-	ContextKey     = "context"
 	LoggerKey      = "logger"
 	AuditLoggerKey = "auditLogger"
 	TraceKey       = "trace"
@@ -30,7 +35,8 @@ const (
 	ShouldBindKey     = "shouldBind"
 	ShouldBindWithKey = "shouldBindWith"
 	TransactionKey    = "tx"
-	ResponseKey       = "response"
+	ClientSubjectKey  = "clientSubject"
+	InputKey          = "input"
 
 	BindingUri           BindingType = "uri"
 	BindingForm          BindingType = "form"
@@ -0,0 +1,242 @@
+package ctxkit
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mj37yhyy/gowb/pkg/cache"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/sirupsen/logrus"
+)
+
+// key 上下文键，未导出的类型避免与其他库冲突
+type key int
+
+const (
+	configKey key = iota
+	bodyKey
+	headerKey
+	paramsKey
+	requestKey
+	binderKey
+	txKey
+	loggerKey
+	auditKey
+	traceKey
+	inputKey
+	clientSubjectKey
+	responseCacheKey
+)
+
+// Binder 参数绑定函数
+type Binder struct {
+	Bind     func(obj interface{}) error
+	BindWith func(obj interface{}, bt constant.BindingType) error
+}
+
+// AuditLogParams 审计日志参数
+type AuditLogParams struct {
+	Module     string
+	Cluster    string
+	Namespace  string
+	Operate    string
+	ObjectType string
+	Object     string
+	LogLevel   logrus.Level
+
+	IsGenerateMsg bool
+}
+
+// AuditFunc 根据参数生成审计日志对象与消息
+type AuditFunc func(params AuditLogParams) (*logrus.Entry, string)
+
+/*
+同时写入typed key与旧版本的字符串键，弃用期内直接读取字符串键的代码仍可使用
+*/
+func withValue(ctx context.Context, k key, legacy string, v interface{}) context.Context {
+	return context.WithValue(context.WithValue(ctx, legacy, v), k, v)
+}
+
+// value 读取typed key，兼容旧版本以字符串为键写入的上下文
+func value(ctx context.Context, k key, legacy string) interface{} {
+	if v := ctx.Value(k); v != nil {
+		return v
+	}
+	return ctx.Value(legacy)
+}
+
+func WithConfig(ctx context.Context, conf config.Config) context.Context {
+	return withValue(ctx, configKey, constant.ConfigKey, conf)
+}
+
+// Config 返回应用配置，不存在时返回零值
+func Config(ctx context.Context) config.Config {
+	conf, _ := value(ctx, configKey, constant.ConfigKey).(config.Config)
+	return conf
+}
+
+func WithBody(ctx context.Context, body []byte) context.Context {
+	return withValue(ctx, bodyKey, constant.BodyKey, body)
+}
+
+// Body 返回请求体，不存在时返回nil
+func Body(ctx context.Context) []byte {
+	body, _ := value(ctx, bodyKey, constant.BodyKey).([]byte)
+	return body
+}
+
+func WithHeader(ctx context.Context, header http.Header) context.Context {
+	return withValue(ctx, headerKey, constant.HeaderKey, header)
+}
+
+// Header 返回请求头，不存在时返回空Header
+func Header(ctx context.Context) http.Header {
+	if header, ok := value(ctx, headerKey, constant.HeaderKey).(http.Header); ok {
+		return header
+	}
+	return http.Header{}
+}
+
+func WithParams(ctx context.Context, params map[string][]string) context.Context {
+	return withValue(ctx, paramsKey, constant.ParamsKey, params)
+}
+
+// Params 返回path、query与表单参数，不存在时返回空map
+func Params(ctx context.Context) map[string][]string {
+	if params, ok := value(ctx, paramsKey, constant.ParamsKey).(map[string][]string); ok {
+		return params
+	}
+	return map[string][]string{}
+}
+
+func WithRequest(ctx context.Context, req *http.Request) context.Context {
+	return withValue(ctx, requestKey, constant.RequestKey, req)
+}
+
+// Request 返回原始请求，不存在时返回nil
+func Request(ctx context.Context) *http.Request {
+	req, _ := value(ctx, requestKey, constant.RequestKey).(*http.Request)
+	return req
+}
+
+func WithBinder(ctx context.Context, b Binder) context.Context {
+	if b.Bind != nil {
+		ctx = context.WithValue(ctx, constant.ShouldBindKey, b.Bind)
+	}
+	if b.BindWith != nil {
+		ctx = context.WithValue(ctx, constant.ShouldBindWithKey, b.BindWith)
+	}
+	return context.WithValue(ctx, binderKey, b)
+}
+
+// Bind 按请求的Content-Type绑定参数，没有绑定函数时按JSON解析Body
+func Bind(ctx context.Context, obj interface{}) error {
+	if b, ok := ctx.Value(binderKey).(Binder); ok && b.Bind != nil {
+		return b.Bind(obj)
+	}
+	if bind, ok := ctx.Value(constant.ShouldBindKey).(func(interface{}) error); ok {
+		return bind(obj)
+	}
+	return json.Unmarshal(Body(ctx), obj)
+}
+
+// BindWith 按指定类型绑定参数，没有绑定函数时按JSON解析Body
+func BindWith(ctx context.Context, obj interface{}, bt constant.BindingType) error {
+	if b, ok := ctx.Value(binderKey).(Binder); ok && b.BindWith != nil {
+		return b.BindWith(obj, bt)
+	}
+	if bind, ok := ctx.Value(constant.ShouldBindWithKey).(func(interface{}, constant.BindingType) error); ok {
+		return bind(obj, bt)
+	}
+	return json.Unmarshal(Body(ctx), obj)
+}
+
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return withValue(ctx, txKey, constant.TransactionKey, tx)
+}
+
+// Tx 返回Router.OpenFlatTransaction开启的事务，未开启时返回nil
+func Tx(ctx context.Context) *gorm.DB {
+	tx, _ := value(ctx, txKey, constant.TransactionKey).(*gorm.DB)
+	return tx
+}
+
+func WithLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	return withValue(ctx, loggerKey, constant.LoggerKey, logger)
+}
+
+// Logger 返回带有自定义字段的日志对象，不存在时返回标准日志对象
+func Logger(ctx context.Context) *logrus.Entry {
+	if logger, ok := value(ctx, loggerKey, constant.LoggerKey).(*logrus.Entry); ok {
+		return logger
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+func WithAudit(ctx context.Context, f AuditFunc) context.Context {
+	// 旧版本以未命名的函数类型断言
+	ctx = context.WithValue(ctx, constant.AuditLoggerKey, (func(AuditLogParams) (*logrus.Entry, string))(f))
+	return context.WithValue(ctx, auditKey, f)
+}
+
+// Audit 返回审计日志对象与消息，不存在审计函数时返回仅带审计字段的日志对象
+func Audit(ctx context.Context, params AuditLogParams) (*logrus.Entry, string) {
+	if f, ok := ctx.Value(auditKey).(AuditFunc); ok {
+		return f(params)
+	}
+	return Logger(ctx).WithFields(logrus.Fields{
+		"AuditLog":                  true,
+		constant.AuditModuleKey:     params.Module,
+		constant.AuditOperateKey:    params.Operate,
+		constant.AuditObjectTypeKey: params.ObjectType,
+		constant.AuditObjectKey:     params.Object,
+		constant.AuditLogLevelKey:   params.LogLevel,
+	}), ""
+}
+
+func WithTrace(ctx context.Context, trace map[string]string) context.Context {
+	return withValue(ctx, traceKey, constant.TraceKey, trace)
+}
+
+// Trace 返回配置的链路追踪请求头，不存在时返回空map
+func Trace(ctx context.Context) map[string]string {
+	if trace, ok := value(ctx, traceKey, constant.TraceKey).(map[string]string); ok {
+		return trace
+	}
+	return map[string]string{}
+}
+
+func WithInput(ctx context.Context, input interface{}) context.Context {
+	return withValue(ctx, inputKey, constant.InputKey, input)
+}
+
+// Input 返回InputType解码并校验后的实例（指针），不存在时返回nil
+func Input(ctx context.Context) interface{} {
+	return ctx.Value(inputKey)
+}
+
+func WithClientSubject(ctx context.Context, subject pkix.Name) context.Context {
+	return withValue(ctx, clientSubjectKey, constant.ClientSubjectKey, subject)
+}
+
+// ClientSubject 返回mTLS校验通过的客户端证书主题
+func ClientSubject(ctx context.Context) (pkix.Name, bool) {
+	subject, ok := ctx.Value(clientSubjectKey).(pkix.Name)
+	return subject, ok
+}
+
+func WithResponseCache(ctx context.Context, c *cache.Cache) context.Context {
+	return context.WithValue(ctx, responseCacheKey, c)
+}
+
+// ResponseCache 返回服务端响应缓存，用于在数据变化后使缓存失效，不存在时返回nil，nil的Invalidate不做任何事
+//
+//	ctxkit.ResponseCache(ctx).Invalidate(ctx, "/regions")
+func ResponseCache(ctx context.Context) *cache.Cache {
+	c, _ := ctx.Value(responseCacheKey).(*cache.Cache)
+	return c
+}
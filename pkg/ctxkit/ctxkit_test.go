@@ -0,0 +1,57 @@
+package ctxkit
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLegacyKeys(t *testing.T) {
+	bg := context.Background()
+	cases := []struct {
+		name string
+		ctx  context.Context
+		key  string
+		ok   func(v interface{}) bool
+	}{
+		{"config", WithConfig(bg, config.Config{}), constant.ConfigKey, func(v interface{}) bool { _, ok := v.(config.Config); return ok }},
+		{"body", WithBody(bg, []byte("{}")), constant.BodyKey, func(v interface{}) bool { _, ok := v.([]byte); return ok }},
+		{"header", WithHeader(bg, http.Header{}), constant.HeaderKey, func(v interface{}) bool { _, ok := v.(http.Header); return ok }},
+		{"params", WithParams(bg, map[string][]string{}), constant.ParamsKey, func(v interface{}) bool { _, ok := v.(map[string][]string); return ok }},
+		{"request", WithRequest(bg, &http.Request{}), constant.RequestKey, func(v interface{}) bool { _, ok := v.(*http.Request); return ok }},
+		{"tx", WithTx(bg, &gorm.DB{}), constant.TransactionKey, func(v interface{}) bool { _, ok := v.(*gorm.DB); return ok }},
+		{"logger", WithLogger(bg, logrus.NewEntry(logrus.StandardLogger())), constant.LoggerKey, func(v interface{}) bool { _, ok := v.(*logrus.Entry); return ok }},
+		{"trace", WithTrace(bg, map[string]string{}), constant.TraceKey, func(v interface{}) bool { _, ok := v.(map[string]string); return ok }},
+		{"input", WithInput(bg, &struct{}{}), constant.InputKey, func(v interface{}) bool { return v != nil }},
+		{"clientSubject", WithClientSubject(bg, pkix.Name{}), constant.ClientSubjectKey, func(v interface{}) bool { _, ok := v.(pkix.Name); return ok }},
+		{"shouldBind", WithBinder(bg, Binder{Bind: func(interface{}) error { return nil }}), constant.ShouldBindKey,
+			func(v interface{}) bool { _, ok := v.(func(interface{}) error); return ok }},
+		{"shouldBindWith", WithBinder(bg, Binder{BindWith: func(interface{}, constant.BindingType) error { return nil }}), constant.ShouldBindWithKey,
+			func(v interface{}) bool { _, ok := v.(func(interface{}, constant.BindingType) error); return ok }},
+		{"auditLogger", WithAudit(bg, func(AuditLogParams) (*logrus.Entry, string) { return nil, "" }), constant.AuditLoggerKey,
+			func(v interface{}) bool { _, ok := v.(func(AuditLogParams) (*logrus.Entry, string)); return ok }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if v := c.ctx.Value(c.key); !c.ok(v) {
+				t.Fatalf("legacy key %q = %#v", c.key, v)
+			}
+		})
+	}
+}
+
+func TestLegacyRead(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.BodyKey, []byte("x"))
+	if string(Body(ctx)) != "x" {
+		t.Fatalf("Body = %q", Body(ctx))
+	}
+	if Tx(ctx) != nil || Request(ctx) != nil {
+		t.Fatal("missing values should be nil")
+	}
+}
@@ -3,8 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
-	"github.com/mj37yhyy/gowb/pkg/config"
-	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
 	"github.com/mj37yhyy/gowb/pkg/utils"
 	"log"
 	"time"
@@ -20,7 +19,7 @@ const NETWORK = "tcp"
 func InitMysql(c context.Context) error {
 
 	// 获取配置
-	conf := c.Value(constant.ConfigKey).(config.Config)
+	conf := ctxkit.Config(c)
 	var err error
 	dsn := fmt.Sprintf("%s:%s@%s(%s:%s)/%s?%s",
 		conf.Mysql.UserName,
@@ -48,3 +47,11 @@ func InitMysql(c context.Context) error {
 	DB.LogMode(true)
 	return nil
 }
+
+// Close 关闭连接池
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Close()
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/utils"
+)
+
+var Redis *redis.Client
+
+func InitRedis(c context.Context) error {
+	// 获取配置
+	conf := ctxkit.Config(c)
+	log.Println("redis connecting " + conf.Redis.Addr)
+	client := redis.NewClient(&redis.Options{
+		Addr:         conf.Redis.Addr,
+		Password:     conf.Redis.Password,
+		DB:           conf.Redis.DB,
+		PoolSize:     conf.Redis.PoolSize,
+		DialTimeout:  utils.If(conf.Redis.DialTimeout <= 0, 5*time.Second, conf.Redis.DialTimeout*time.Second).(time.Duration),
+		ReadTimeout:  utils.If(conf.Redis.ReadTimeout <= 0, 3*time.Second, conf.Redis.ReadTimeout*time.Second).(time.Duration),
+		WriteTimeout: utils.If(conf.Redis.WriteTimeout <= 0, 3*time.Second, conf.Redis.WriteTimeout*time.Second).(time.Duration),
+	})
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		return err
+	}
+	Redis = client
+	return nil
+}
+
+// CloseRedis 关闭连接池
+func CloseRedis() error {
+	if Redis == nil {
+		return nil
+	}
+	return Redis.Close()
+}
@@ -0,0 +1,213 @@
+package listener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// envListeners 传递给新进程的监听地址与文件描述符映射，JSON格式
+	envListeners = "GOWB_LISTENERS"
+	// envReadyFd 新进程通知父进程就绪的管道描述符
+	envReadyFd = "GOWB_READY_FD"
+)
+
+var (
+	mu        sync.Mutex
+	active    = make(map[string]*trackedListener)
+	inherited map[string]int
+	parsed    bool
+)
+
+// trackedListener 登记正在监听的地址，关闭时移除
+type trackedListener struct {
+	net.Listener
+	address string
+}
+
+func (l *trackedListener) Close() error {
+	mu.Lock()
+	if active[l.address] == l {
+		delete(active, l.address)
+	}
+	mu.Unlock()
+	return l.Listener.Close()
+}
+
+func track(address string, ln net.Listener) net.Listener {
+	t := &trackedListener{Listener: ln, address: address}
+	mu.Lock()
+	active[address] = t
+	mu.Unlock()
+	return t
+}
+
+// takeInherited 取出父进程交接的同一地址的监听器，每个描述符只能取一次
+func takeInherited(address string) (net.Listener, error) {
+	mu.Lock()
+	if !parsed {
+		parsed = true
+		if v := os.Getenv(envListeners); v != "" {
+			if err := json.Unmarshal([]byte(v), &inherited); err != nil {
+				log.Printf("[error] invalid %s: %s", envListeners, err)
+			}
+		}
+	}
+	fd, ok := inherited[address]
+	delete(inherited, address)
+	mu.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("inherited-%s", address))
+	if f == nil {
+		return nil, fmt.Errorf("invalid inherited fd %d for %s", fd, address)
+	}
+	defer f.Close()
+	log.Printf("[info] inherited listener %s from parent process", address)
+	return net.FileListener(f)
+}
+
+// Child 热重启启动的新进程
+type Child struct {
+	Process *os.Process
+	ready   chan bool
+}
+
+// WaitReady 等待新进程调用NotifyReady。新进程在就绪前退出或超时未就绪时返回错误，
+// 超时的新进程会被结束，调用方应继续提供服务
+func (c *Child) WaitReady(timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ok := <-c.ready:
+		if ok {
+			return nil
+		}
+		c.Process.Wait()
+		return fmt.Errorf("new process %d exited before ready", c.Process.Pid)
+	case <-timer.C:
+		c.Process.Kill()
+		c.Process.Wait()
+		return fmt.Errorf("new process %d not ready within %s", c.Process.Pid, timeout)
+	}
+}
+
+// NotifyReady 热重启的新进程完成启动后调用，通知父进程开始停机，不是由Fork启动时不做任何事
+func NotifyReady() error {
+	v := os.Getenv(envReadyFd)
+	if v == "" {
+		return nil
+	}
+	os.Unsetenv(envReadyFd)
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", envReadyFd, v)
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	if f == nil {
+		return fmt.Errorf("invalid ready fd %d", fd)
+	}
+	defer f.Close()
+	_, err = f.Write([]byte{1})
+	return err
+}
+
+// Fork 以相同的参数启动当前程序的新进程，并把所有正在监听的socket交给它。
+// 调用方应在Child.WaitReady返回nil后再走正常的优雅停机流程排空自身的请求
+func Fork() (*Child, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(active) == 0 {
+		return nil, errors.New("no active listeners to hand off")
+	}
+
+	fds := make(map[string]int)
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for address, t := range active {
+		var (
+			f   *os.File
+			err error
+		)
+		switch ln := t.Listener.(type) {
+		case *net.TCPListener:
+			f, err = ln.File()
+		case *net.UnixListener:
+			// 旧进程关闭监听时不能删除socket文件
+			ln.SetUnlinkOnClose(false)
+			f, err = ln.File()
+		default:
+			err = fmt.Errorf("listener %s of type %T cannot be handed off", address, ln)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// ExtraFiles中第i个文件在子进程中的描述符为3+i
+		fds[address] = 3 + len(files)
+		files = append(files, f)
+	}
+	mapping, err := json.Marshal(fds)
+	if err != nil {
+		return nil, err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	// 新进程就绪后写入一个字节；未写入就读到EOF说明新进程已退出
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, w)
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(childEnv(), envListeners+"="+string(mapping), envReadyFd+"="+strconv.Itoa(2+len(files)))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	child := &Child{Process: cmd.Process, ready: make(chan bool, 1)}
+	go func() {
+		defer r.Close()
+		b := make([]byte, 1)
+		n, _ := r.Read(b)
+		child.ready <- n == 1
+	}()
+	return child, nil
+}
+
+// childEnv 去掉交接与socket activation相关的环境变量，避免子进程误用
+func childEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, envListeners+"=") ||
+			strings.HasPrefix(kv, envReadyFd+"=") ||
+			strings.HasPrefix(kv, "LISTEN_PID=") ||
+			strings.HasPrefix(kv, "LISTEN_FDS=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
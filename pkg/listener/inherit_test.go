@@ -0,0 +1,72 @@
+//go:build !windows
+// +build !windows
+
+package listener
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// envTestChild 由Fork启动的测试进程按该变量模拟新进程的行为
+const envTestChild = "GOWB_TEST_CHILD"
+
+func TestForkWaitReady(t *testing.T) {
+	switch os.Getenv(envTestChild) {
+	case "ready":
+		NotifyReady()
+		// 测试中os.Exit(0)会被视为错误，退出码与父进程无关
+		os.Exit(3)
+	case "crash":
+		os.Exit(1)
+	case "hang":
+		time.Sleep(time.Minute)
+		os.Exit(3)
+	}
+
+	ln, err := Listen("127.0.0.1:0", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	cases := []struct {
+		behavior string
+		ready    bool
+	}{
+		{"ready", true},
+		{"crash", false},
+		{"hang", false},
+	}
+	for _, c := range cases {
+		os.Setenv(envTestChild, c.behavior)
+		child, err := Fork()
+		os.Unsetenv(envTestChild)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		err = child.WaitReady(2 * time.Second)
+		if (err == nil) != c.ready {
+			t.Errorf("%s: WaitReady = %v", c.behavior, err)
+		}
+		if c.behavior == "crash" && time.Since(start) > time.Second {
+			t.Errorf("crash: WaitReady waited %s for a dead child", time.Since(start))
+		}
+		if c.behavior == "hang" {
+			// 超时后新进程应已被结束
+			if child.Process.Signal(syscall.Signal(0)) == nil {
+				t.Errorf("hang: child still running after timeout")
+			}
+		}
+	}
+}
+
+func TestNotifyReadyWithoutParent(t *testing.T) {
+	os.Unsetenv(envReadyFd)
+	if err := NotifyReady(); err != nil {
+		t.Fatal(err)
+	}
+}
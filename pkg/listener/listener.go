@@ -0,0 +1,123 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	schemeTCP  = "tcp://"
+	schemeUnix = "unix://"
+	schemeFd   = "fd://"
+
+	// systemd socket activation 传入的第一个文件描述符
+	listenFdsStart = 3
+)
+
+// Options 监听选项
+type Options struct {
+	KeepAlive  time.Duration // TCP keep-alive探测间隔，0使用系统默认值，负数关闭
+	SocketMode os.FileMode   // unix socket文件权限，0表示不修改
+}
+
+// Listen 根据地址创建监听器，支持以下格式：
+//
+//	host:port / tcp://host:port  TCP端口
+//	unix:///path/to/sock         Unix domain socket
+//	fd://3                       继承的文件描述符（如systemd socket activation）
+func Listen(address string, opts Options) (net.Listener, error) {
+	// 优先使用热重启时父进程交接的监听器
+	ln, err := takeInherited(address)
+	if err == nil && ln == nil {
+		ln, err = listen(address, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return track(address, ln), nil
+}
+
+func listen(address string, opts Options) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(address, schemeUnix):
+		return listenUnix(strings.TrimPrefix(address, schemeUnix), opts)
+	case strings.HasPrefix(address, schemeFd):
+		return listenFd(strings.TrimPrefix(address, schemeFd))
+	default:
+		lc := net.ListenConfig{KeepAlive: opts.KeepAlive}
+		return lc.Listen(context.Background(), "tcp", strings.TrimPrefix(address, schemeTCP))
+	}
+}
+
+// ParseMode 解析八进制的文件权限，如"0660"
+func ParseMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid socket mode %q: %v", mode, err)
+	}
+	return os.FileMode(m), nil
+}
+
+func listenUnix(path string, opts Options) (net.Listener, error) {
+	// 清理上次异常退出残留的socket文件
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SocketMode != 0 {
+		if err := os.Chmod(path, opts.SocketMode); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
+
+func listenFd(s string) (net.Listener, error) {
+	fd, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listener fd %q", s)
+	}
+	if err := checkListenFds(fd); err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("listener-fd-%d", fd))
+	if f == nil {
+		return nil, fmt.Errorf("invalid listener fd %d", fd)
+	}
+	defer f.Close()
+	// FileListener会复制文件描述符，原描述符可以关闭
+	return net.FileListener(f)
+}
+
+// checkListenFds 设置了LISTEN_PID/LISTEN_FDS时校验描述符属于本进程
+func checkListenFds(fd int) error {
+	pid := os.Getenv("LISTEN_PID")
+	if pid == "" {
+		return nil
+	}
+	if pid != strconv.Itoa(os.Getpid()) {
+		return fmt.Errorf("LISTEN_PID %s does not match current process", pid)
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil {
+		return fmt.Errorf("invalid LISTEN_FDS: %v", err)
+	}
+	if fd < listenFdsStart || fd >= listenFdsStart+n {
+		return fmt.Errorf("fd %d not in LISTEN_FDS range [%d, %d)", fd, listenFdsStart, listenFdsStart+n)
+	}
+	return nil
+}
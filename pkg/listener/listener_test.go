@@ -0,0 +1,167 @@
+//go:build !windows
+// +build !windows
+
+package listener
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0660", 0660, false},
+		{"600", 0600, false},
+		{"0980", 0, true},
+	}
+	for _, c := range cases {
+		got, err := ParseMode(c.in)
+		if (err != nil) != c.wantErr || got != c.want {
+			t.Errorf("%q: got %v %v", c.in, got, err)
+		}
+	}
+}
+
+// echo 通过监听器收发一个字节，确认监听器可用
+func echo(t *testing.T, ln net.Listener) {
+	t.Helper()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b := make([]byte, 1)
+		conn.Read(b)
+		conn.Write(b)
+	}()
+	conn, err := net.Dial(ln.Addr().Network(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	b := []byte{'x'}
+	if _, err := conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Read(b); err != nil || b[0] != 'x' {
+		t.Fatalf("echo got %q %v", b, err)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gowb-listener")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.sock")
+
+	// 模拟异常退出残留的socket文件
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := Listen("unix://"+path, Options{SocketMode: 0660})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0660 {
+		t.Errorf("socket mode %v", fi.Mode().Perm())
+	}
+	echo(t, ln)
+	ln.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket file not removed: %v", err)
+	}
+
+	// 不是socket的文件不会被删除
+	if err := ioutil.WriteFile(path, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if ln, err := Listen("unix://"+path, Options{}); err == nil {
+		ln.Close()
+		t.Error("listening over a regular file should fail")
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "data" {
+		t.Errorf("regular file changed: %q", b)
+	}
+}
+
+// dupListenerFd 复制一个TCP监听器的描述符，Listen负责关闭复制出的描述符
+func dupListenerFd(t *testing.T) (int, net.Listener) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd, ln
+}
+
+func TestListenFd(t *testing.T) {
+	fd, orig := dupListenerFd(t)
+	defer orig.Close()
+	ln, err := Listen("fd://"+strconv.Itoa(fd), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if ln.Addr().String() != orig.Addr().String() {
+		t.Errorf("got %s, want %s", ln.Addr(), orig.Addr())
+	}
+	echo(t, ln)
+
+	if _, err := Listen("fd://abc", Options{}); err == nil {
+		t.Error("invalid fd should fail")
+	}
+}
+
+// 设置了LISTEN_PID/LISTEN_FDS时只接受属于本进程且在范围内的描述符
+func TestListenFdSocketActivation(t *testing.T) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	pid := strconv.Itoa(os.Getpid())
+	cases := []struct {
+		pid, fds string
+		fd       int
+	}{
+		{"1", "1", listenFdsStart},
+		{pid, "x", listenFdsStart},
+		{pid, "1", listenFdsStart + 1},
+		{pid, "1", listenFdsStart - 1},
+	}
+	for _, c := range cases {
+		os.Setenv("LISTEN_PID", c.pid)
+		os.Setenv("LISTEN_FDS", c.fds)
+		if ln, err := Listen("fd://"+strconv.Itoa(c.fd), Options{}); err == nil {
+			ln.Close()
+			t.Errorf("LISTEN_PID=%s LISTEN_FDS=%s fd %d should fail", c.pid, c.fds, c.fd)
+		}
+	}
+}
@@ -2,15 +2,14 @@ package log
 
 import (
 	"context"
-	"github.com/mj37yhyy/gowb/pkg/config"
-	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
 	logger "github.com/sirupsen/logrus"
 	"os"
 )
 
 func InitLogger(c context.Context) error {
 	// 获取配置
-	conf := c.Value(constant.ConfigKey).(config.Config)
+	conf := ctxkit.Config(c)
 
 	// 日志json格式
 	if conf.Log.Formatter == "json" {
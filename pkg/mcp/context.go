@@ -5,16 +5,21 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
 	"github.com/sirupsen/logrus"
 	"net/http"
 )
 
 // CreateContextFromMCP 从MCP请求创建gowb标准Context
 func CreateContextFromMCP(args map[string]interface{}, authConfig *AuthConfig, logger *logrus.Entry) context.Context {
-	ctx := context.Background()
+	return createContext(context.Background(), args, authConfig, logger)
+}
+
+// createContext 基于父上下文创建gowb标准Context
+func createContext(ctx context.Context, args map[string]interface{}, authConfig *AuthConfig, logger *logrus.Entry) context.Context {
 
 	// 添加logger
-	ctx = context.WithValue(ctx, constant.LoggerKey, logger)
+	ctx = ctxkit.WithLogger(ctx, logger)
 
 	// 构造Header
 	header := http.Header{}
@@ -44,11 +49,11 @@ func CreateContextFromMCP(args map[string]interface{}, authConfig *AuthConfig, l
 		delete(args, "request_id")
 	}
 
-	ctx = context.WithValue(ctx, constant.HeaderKey, header)
+	ctx = ctxkit.WithHeader(ctx, header)
 
 	// 构造Body（剩余的参数作为JSON body）
 	bodyBytes, _ := json.Marshal(args)
-	ctx = context.WithValue(ctx, constant.BodyKey, bodyBytes)
+	ctx = ctxkit.WithBody(ctx, bodyBytes)
 
 	// 构造Params（用于兼容某些Handler）
 	params := make(map[string][]string)
@@ -57,26 +62,26 @@ func CreateContextFromMCP(args map[string]interface{}, authConfig *AuthConfig, l
 			params[k] = []string{str}
 		}
 	}
-	ctx = context.WithValue(ctx, constant.ParamsKey, params)
-
-	// 添加ShouldBind函数（用于参数绑定）
-	ctx = context.WithValue(ctx, constant.ShouldBindKey, func(obj interface{}) error {
-		return json.Unmarshal(bodyBytes, obj)
-	})
+	ctx = ctxkit.WithParams(ctx, params)
 
-	ctx = context.WithValue(ctx, constant.ShouldBindWithKey,
-		func(obj interface{}, bt constant.BindingType) error {
+	// 添加绑定函数（用于参数绑定）
+	ctx = ctxkit.WithBinder(ctx, ctxkit.Binder{
+		Bind: func(obj interface{}) error {
+			return json.Unmarshal(bodyBytes, obj)
+		},
+		BindWith: func(obj interface{}, bt constant.BindingType) error {
 			if bt == constant.BindingJson {
 				return json.Unmarshal(bodyBytes, obj)
 			}
 			// 其他类型暂不支持
 			return json.Unmarshal(bodyBytes, obj)
-		})
+		},
+	})
 
 	// 构造Request（某些Handler可能需要）
 	req, _ := http.NewRequest("POST", "/mcp", bytes.NewReader(bodyBytes))
 	req.Header = header
-	ctx = context.WithValue(ctx, constant.RequestKey, req)
+	ctx = ctxkit.WithRequest(ctx, req)
 
 	return ctx
 }
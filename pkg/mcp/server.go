@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -126,6 +127,11 @@ func (s *Server) generateTools() {
 
 // HandleRequest 处理MCP请求
 func (s *Server) HandleRequest(reqData []byte) []byte {
+	return s.HandleRequestContext(context.Background(), reqData)
+}
+
+// HandleRequestContext 处理MCP请求，ctx作为Handler上下文的父上下文
+func (s *Server) HandleRequestContext(ctx context.Context, reqData []byte) []byte {
 	var req MCPRequest
 	if err := json.Unmarshal(reqData, &req); err != nil {
 		s.logger.Errorf("Failed to unmarshal request: %v", err)
@@ -140,7 +146,7 @@ func (s *Server) HandleRequest(reqData []byte) []byte {
 	case "tools/list":
 		return s.handleListTools(req)
 	case "tools/call":
-		return s.handleCallTool(req)
+		return s.handleCallTool(ctx, req)
 	default:
 		return s.errorResponse(req.ID, -32601, "Method not found", nil)
 	}
@@ -192,7 +198,7 @@ func (s *Server) handleListTools(req MCPRequest) []byte {
 }
 
 // handleCallTool 处理调用工具请求
-func (s *Server) handleCallTool(req MCPRequest) []byte {
+func (s *Server) handleCallTool(parent context.Context, req MCPRequest) []byte {
 	params := req.Params
 	if params == nil {
 		return s.errorResponse(req.ID, -32602, "Invalid params", nil)
@@ -217,7 +223,7 @@ func (s *Server) handleCallTool(req MCPRequest) []byte {
 	}
 
 	// 创建Context
-	ctx := CreateContextFromMCP(arguments, s.authConfig, s.logger)
+	ctx := createContext(parent, arguments, s.authConfig, s.logger)
 
 	// 调用Handler
 	resp, httpStatus := action.Handler(ctx)
@@ -1,12 +1,21 @@
 package transport
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/listener"
 	"github.com/mj37yhyy/gowb/pkg/mcp"
+	"github.com/mj37yhyy/gowb/pkg/stream"
+	"github.com/mj37yhyy/gowb/pkg/utils"
+	"github.com/mj37yhyy/gowb/pkg/web/middleware"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -18,8 +27,16 @@ type SSETransport struct {
 	endpoint string
 	engine   *gin.Engine
 	httpSrv  *http.Server
+	tls      *tls.Config
+	lnOpts   listener.Options
+	cors     config.CORS
+	listener net.Listener
 	clients  map[string]*SSEClient
 	mu       sync.RWMutex
+	closing  chan struct{}
+	closeMu  sync.Once
+	done     chan struct{}
+	err      error
 }
 
 // SSEClient SSE客户端
@@ -35,14 +52,38 @@ func NewSSETransport(server *mcp.Server, endpoint string) *SSETransport {
 		server:   server,
 		endpoint: endpoint,
 		clients:  make(map[string]*SSEClient),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 }
 
+// SetTLS 启用TLS，需在Start之前调用
+func (t *SSETransport) SetTLS(c *tls.Config) {
+	t.tls = c
+}
+
+// SetListenOptions 设置监听选项，需在Start之前调用
+func (t *SSETransport) SetListenOptions(opts listener.Options) {
+	t.lnOpts = opts
+}
+
+// SetCORS 设置跨域策略，未设置时允许所有来源，需在Start之前调用
+func (t *SSETransport) SetCORS(conf config.CORS) {
+	t.cors = conf
+}
+
 // Start 启动SSE传输
 func (t *SSETransport) Start() error {
 	gin.SetMode(gin.ReleaseMode)
 	t.engine = gin.New()
 	t.engine.Use(gin.Recovery())
+	if !t.cors.Disabled {
+		cors, err := middleware.CORS(t.cors)
+		if err != nil {
+			return err
+		}
+		t.engine.Use(cors)
+	}
 
 	// SSE endpoint
 	t.engine.GET("/sse", t.handleSSE)
@@ -56,15 +97,30 @@ func (t *SSETransport) Start() error {
 	})
 
 	t.httpSrv = &http.Server{
-		Addr:    t.endpoint,
-		Handler: t.engine,
+		Addr:      t.endpoint,
+		Handler:   t.engine,
+		TLSConfig: t.tls,
 	}
 
-	log.Printf("[MCP] Starting SSE transport on %s", t.endpoint)
+	ln, err := listener.Listen(t.endpoint, t.lnOpts)
+	if err != nil {
+		return err
+	}
+	t.listener = ln
+
+	log.Printf("[MCP] Starting SSE transport on %s", t.Addr())
 
 	go func() {
-		if err := t.httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("[MCP] SSE server error: %v", err)
+		defer close(t.done)
+		var err error
+		if t.tls != nil {
+			err = t.httpSrv.ServeTLS(ln, "", "")
+		} else {
+			err = t.httpSrv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			t.err = err
+			log.Printf("[MCP] SSE server error: %v", err)
 		}
 	}()
 
@@ -79,6 +135,39 @@ func (t *SSETransport) Stop() error {
 	return nil
 }
 
+// Shutdown 优雅停止SSE传输：断开所有SSE客户端并等待进行中的请求结束，超时后强制关闭
+func (t *SSETransport) Shutdown(ctx context.Context) error {
+	if t.httpSrv == nil {
+		return nil
+	}
+	t.closeMu.Do(func() {
+		close(t.closing)
+	})
+	if err := t.httpSrv.Shutdown(ctx); err != nil {
+		t.httpSrv.Close()
+		return err
+	}
+	return nil
+}
+
+// Addr 实际监听的地址
+func (t *SSETransport) Addr() string {
+	if t.listener != nil {
+		return t.listener.Addr().String()
+	}
+	return t.endpoint
+}
+
+// Done 服务退出后关闭
+func (t *SSETransport) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err 服务异常退出的原因，须在Done关闭后读取
+func (t *SSETransport) Err() error {
+	return t.err
+}
+
 // handleSSE 处理SSE连接
 func (t *SSETransport) handleSSE(c *gin.Context) {
 	clientID := c.Query("client_id")
@@ -103,30 +192,31 @@ func (t *SSETransport) handleSSE(c *gin.Context) {
 		close(client.Done)
 	}()
 
-	// 设置SSE headers
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Access-Control-Allow-Origin", "*")
+	w := stream.NewWriter(c.Request.Context(), c.Writer, stream.SSE)
 
 	// 发送连接成功消息
-	c.SSEvent("connected", map[string]string{"client_id": clientID})
-	c.Writer.Flush()
+	if w.Event("connected", map[string]string{"client_id": clientID}) != nil {
+		return
+	}
 
-	// 保持连接并发送消息
+	// 保持连接并发送消息，写入失败说明客户端已断开
 	for {
+		var err error
 		select {
 		case msg := <-client.Messages:
-			c.SSEvent("message", string(msg))
-			c.Writer.Flush()
+			err = w.Event("message", string(msg))
 		case <-client.Done:
 			return
-		case <-c.Request.Context().Done():
+		case <-t.closing:
+			return
+		case <-w.Done():
 			return
 		case <-time.After(30 * time.Second):
 			// 发送心跳
-			c.SSEvent("ping", "")
-			c.Writer.Flush()
+			err = w.Event("ping", "")
+		}
+		if err != nil {
+			return
 		}
 	}
 }
@@ -146,8 +236,12 @@ func (t *SSETransport) handleMessage(c *gin.Context) {
 		return
 	}
 
-	// 处理MCP请求
-	response := t.server.HandleRequest(body)
+	// 处理MCP请求，mTLS校验通过的客户端证书主题放入上下文
+	ctx := c.Request.Context()
+	if subject, ok := utils.ClientSubject(c.Request.TLS); ok {
+		ctx = ctxkit.WithClientSubject(ctx, subject)
+	}
+	response := t.server.HandleRequestContext(ctx, body)
 
 	// 如果是SSE客户端，通过SSE发送响应
 	t.mu.RLock()
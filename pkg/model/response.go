@@ -1,14 +1,22 @@
 package model
 
 type Response struct {
-	RequestId string      `json:"RequestId,omitempty"`
-	Error     *ErrorInfo  `json:"Error,omitempty"`
-	Data      interface{} `json:"Data,omitempty"`
+	RequestId string      `json:"RequestId,omitempty" xml:"RequestId,omitempty" yaml:"RequestId,omitempty"`
+	Error     *ErrorInfo  `json:"Error,omitempty" xml:"Error,omitempty" yaml:"Error,omitempty"`
+	Data      interface{} `json:"Data,omitempty" xml:"Data,omitempty" yaml:"Data,omitempty"`
 }
 
 type ErrorInfo struct {
-	Code    string `json:"Code,omitempty"`
-	Message string `json:"Message,omitempty"`
+	Code    string       `json:"Code,omitempty" xml:"Code,omitempty" yaml:"Code,omitempty"`
+	Message string       `json:"Message,omitempty" xml:"Message,omitempty" yaml:"Message,omitempty"`
+	Fields  []FieldError `json:"Fields,omitempty" xml:"Fields,omitempty" yaml:"Fields,omitempty"`
+}
+
+// FieldError 参数校验失败的字段
+type FieldError struct {
+	Field   string `json:"Field,omitempty" xml:"Field,omitempty" yaml:"Field,omitempty"`
+	Rule    string `json:"Rule,omitempty" xml:"Rule,omitempty" yaml:"Rule,omitempty"`
+	Message string `json:"Message,omitempty" xml:"Message,omitempty" yaml:"Message,omitempty"`
 }
 
 func NewResponse() *Response {
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"fmt"
+	"hash/crc32"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	BalanceRoundRobin     = "roundRobin"
+	BalanceLeastConn      = "leastConn"
+	BalanceConsistentHash = "consistentHash"
+
+	// hashReplicas 一致性哈希中每个上游的虚拟节点数
+	hashReplicas = 160
+)
+
+// backend 单个上游地址及其状态
+type backend struct {
+	url *url.URL
+	// 主动检查的结果，1健康
+	healthy int32
+	// 被动检查：连续失败次数与摘除截止时间（UnixNano）
+	fails     int32
+	downUntil int64
+	// 进行中的请求数，leastConn使用
+	inflight int64
+}
+
+func (b *backend) available() bool {
+	return atomic.LoadInt32(&b.healthy) == 1 && time.Now().UnixNano() >= atomic.LoadInt64(&b.downUntil)
+}
+
+// balancer 从可用的上游中选择一个，exclude为本次请求已经失败过的上游
+type balancer interface {
+	pick(req *http.Request, backends []*backend, exclude map[*backend]bool) *backend
+}
+
+func newBalancer(name, hashKey string, backends []*backend) (balancer, error) {
+	switch name {
+	case "", BalanceRoundRobin:
+		return &roundRobin{}, nil
+	case BalanceLeastConn:
+		return &leastConn{}, nil
+	case BalanceConsistentHash:
+		return newConsistentHash(hashKey, backends)
+	default:
+		return nil, fmt.Errorf("unknown balance %q", name)
+	}
+}
+
+/*
+优先在可用的上游中选择，全部不可用时忽略健康状态，避免因误判导致服务完全中断
+*/
+func candidates(backends []*backend, exclude map[*backend]bool) []*backend {
+	var alive, rest []*backend
+	for _, b := range backends {
+		if exclude[b] {
+			continue
+		}
+		if b.available() {
+			alive = append(alive, b)
+		} else {
+			rest = append(rest, b)
+		}
+	}
+	if len(alive) > 0 {
+		return alive
+	}
+	return rest
+}
+
+type roundRobin struct {
+	next uint32
+}
+
+func (r *roundRobin) pick(_ *http.Request, backends []*backend, exclude map[*backend]bool) *backend {
+	list := candidates(backends, exclude)
+	if len(list) == 0 {
+		return nil
+	}
+	n := atomic.AddUint32(&r.next, 1)
+	return list[(n-1)%uint32(len(list))]
+}
+
+type leastConn struct {
+	roundRobin
+}
+
+func (l *leastConn) pick(_ *http.Request, backends []*backend, exclude map[*backend]bool) *backend {
+	list := candidates(backends, exclude)
+	if len(list) == 0 {
+		return nil
+	}
+	// 从轮询位置开始比较，连接数相同时请求均匀分布
+	start := int(atomic.AddUint32(&l.next, 1) % uint32(len(list)))
+	best := list[start]
+	for i := 1; i < len(list); i++ {
+		b := list[(start+i)%len(list)]
+		if atomic.LoadInt64(&b.inflight) < atomic.LoadInt64(&best.inflight) {
+			best = b
+		}
+	}
+	return best
+}
+
+type consistentHash struct {
+	key    func(req *http.Request) string
+	hashes []uint32
+	ring   map[uint32]*backend
+}
+
+func newConsistentHash(hashKey string, backends []*backend) (*consistentHash, error) {
+	key, err := hashKeyFunc(hashKey)
+	if err != nil {
+		return nil, err
+	}
+	c := &consistentHash{key: key, ring: make(map[uint32]*backend)}
+	for _, b := range backends {
+		for i := 0; i < hashReplicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(fmt.Sprintf("%s#%d", b.url.Host, i)))
+			if _, ok := c.ring[h]; !ok {
+				c.ring[h] = b
+				c.hashes = append(c.hashes, h)
+			}
+		}
+	}
+	sort.Slice(c.hashes, func(i, j int) bool { return c.hashes[i] < c.hashes[j] })
+	return c, nil
+}
+
+func (c *consistentHash) pick(req *http.Request, backends []*backend, exclude map[*backend]bool) *backend {
+	if len(c.hashes) == 0 {
+		return nil
+	}
+	allowed := make(map[*backend]bool)
+	for _, b := range candidates(backends, exclude) {
+		allowed[b] = true
+	}
+	// 从键的位置顺时针找到第一个可用的上游
+	h := crc32.ChecksumIEEE([]byte(c.key(req)))
+	i := sort.Search(len(c.hashes), func(i int) bool { return c.hashes[i] >= h })
+	for n := 0; n < len(c.hashes); n++ {
+		b := c.ring[c.hashes[(i+n)%len(c.hashes)]]
+		if allowed[b] {
+			return b
+		}
+	}
+	return nil
+}
+
+func hashKeyFunc(hashKey string) (func(req *http.Request) string, error) {
+	kind, name := hashKey, ""
+	if i := strings.Index(hashKey, ":"); i >= 0 {
+		kind, name = hashKey[:i], hashKey[i+1:]
+	}
+	switch kind {
+	case "", "ip":
+		return func(req *http.Request) string {
+			host, _, err := net.SplitHostPort(req.RemoteAddr)
+			if err != nil {
+				return req.RemoteAddr
+			}
+			return host
+		}, nil
+	case "path":
+		return func(req *http.Request) string {
+			return req.URL.Path
+		}, nil
+	case "header":
+		return func(req *http.Request) string {
+			return req.Header.Get(name)
+		}, nil
+	case "query":
+		return func(req *http.Request) string {
+			return req.URL.Query().Get(name)
+		}, nil
+	case "cookie":
+		return func(req *http.Request) string {
+			if c, err := req.Cookie(name); err == nil {
+				return c.Value
+			}
+			return ""
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown hashKey %q", hashKey)
+	}
+}
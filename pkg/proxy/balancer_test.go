@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newBackends(hosts ...string) []*backend {
+	backends := make([]*backend, len(hosts))
+	for i, host := range hosts {
+		backends[i] = &backend{url: &url.URL{Scheme: "http", Host: host}, healthy: 1}
+	}
+	return backends
+}
+
+func hosts(picked []*backend) []string {
+	out := make([]string, len(picked))
+	for i, b := range picked {
+		if b != nil {
+			out[i] = b.url.Host
+		}
+	}
+	return out
+}
+
+func TestRoundRobin(t *testing.T) {
+	cases := []struct {
+		name    string
+		setup   func(bs []*backend)
+		exclude []int
+		want    []string
+	}{
+		{"all healthy", nil, nil, []string{"a", "b", "c", "a"}},
+		{"unhealthy skipped", func(bs []*backend) { bs[1].healthy = 0 }, nil, []string{"a", "c", "a", "c"}},
+		{"marked down skipped", func(bs []*backend) { bs[0].downUntil = time.Now().Add(time.Hour).UnixNano() }, nil, []string{"b", "c", "b", "c"}},
+		{"excluded skipped", nil, []int{0, 2}, []string{"b", "b", "b", "b"}},
+		// 全部不可用时忽略健康状态
+		{"all down", func(bs []*backend) { bs[0].healthy, bs[1].healthy, bs[2].healthy = 0, 0, 0 }, nil, []string{"a", "b", "c", "a"}},
+		{"all excluded", nil, []int{0, 1, 2}, []string{"", "", "", ""}},
+	}
+	for _, c := range cases {
+		bs := newBackends("a", "b", "c")
+		if c.setup != nil {
+			c.setup(bs)
+		}
+		exclude := map[*backend]bool{}
+		for _, i := range c.exclude {
+			exclude[bs[i]] = true
+		}
+		r := &roundRobin{}
+		var picked []*backend
+		for range c.want {
+			picked = append(picked, r.pick(nil, bs, exclude))
+		}
+		if got := hosts(picked); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestLeastConn(t *testing.T) {
+	cases := []struct {
+		inflight []int64
+		want     string
+	}{
+		{[]int64{3, 1, 2}, "b"},
+		{[]int64{0, 5, 5}, "a"},
+		{[]int64{4, 4, 0}, "c"},
+	}
+	for _, c := range cases {
+		bs := newBackends("a", "b", "c")
+		for i, n := range c.inflight {
+			bs[i].inflight = n
+		}
+		l := &leastConn{}
+		for i := 0; i < 3; i++ {
+			if got := l.pick(nil, bs, nil).url.Host; got != c.want {
+				t.Errorf("inflight %v: got %s, want %s", c.inflight, got, c.want)
+			}
+		}
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	bs := newBackends("a:80", "b:80", "c:80")
+	c, err := newConsistentHash("header:X-User", bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := func(user string) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User", user)
+		return req
+	}
+
+	seen := map[string]bool{}
+	for _, user := range []string{"u1", "u2", "u3", "u4", "u5", "u6", "u7", "u8"} {
+		first := c.pick(request(user), bs, nil)
+		if again := c.pick(request(user), bs, nil); again != first {
+			t.Errorf("%s: picked %s then %s", user, first.url.Host, again.url.Host)
+		}
+		seen[first.url.Host] = true
+		// 原上游失败后换到另一个上游
+		other := c.pick(request(user), bs, map[*backend]bool{first: true})
+		if other == nil || other == first {
+			t.Errorf("%s: excluding %s picked %v", user, first.url.Host, other)
+		}
+	}
+	if len(seen) < 2 {
+		t.Errorf("all keys hashed to %v", seen)
+	}
+}
+
+func TestHashKeyFunc(t *testing.T) {
+	req := httptest.NewRequest("GET", "/users/1?tenant=t1", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-User", "u1")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "s1"})
+
+	cases := []struct {
+		hashKey string
+		want    string
+		wantErr bool
+	}{
+		{"", "10.0.0.1", false},
+		{"ip", "10.0.0.1", false},
+		{"path", "/users/1", false},
+		{"header:X-User", "u1", false},
+		{"query:tenant", "t1", false},
+		{"cookie:sid", "s1", false},
+		{"cookie:missing", "", false},
+		{"body", "", true},
+	}
+	for _, c := range cases {
+		key, err := hashKeyFunc(c.hashKey)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%q: err = %v", c.hashKey, err)
+			continue
+		}
+		if !c.wantErr && key(req) != c.want {
+			t.Errorf("%q: got %q, want %q", c.hashKey, key(req), c.want)
+		}
+	}
+}
+
+func TestNewBalancer(t *testing.T) {
+	for _, name := range []string{"", BalanceRoundRobin, BalanceLeastConn, BalanceConsistentHash} {
+		if _, err := newBalancer(name, "", newBackends("a")); err != nil {
+			t.Errorf("%q: %v", name, err)
+		}
+	}
+	if _, err := newBalancer("random", "", newBackends("a")); err == nil {
+		t.Error("unknown balance should fail")
+	}
+}
@@ -0,0 +1,22 @@
+package proxy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	upstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gowb_proxy_upstream_requests_total",
+		Help: "Number of requests sent to proxy upstreams, including retries.",
+	}, []string{"route", "upstream", "code"})
+
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gowb_proxy_upstream_duration_seconds",
+		Help:    "Time until the upstream response headers were received.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "upstream"})
+)
+
+func init() {
+	prometheus.MustRegister(upstreamRequests, upstreamDuration)
+}
@@ -0,0 +1,379 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNoUpstream 没有可以转发的上游
+var ErrNoUpstream = errors.New("proxy: no upstream available")
+
+// retryBodyLimit 为重试而缓存的请求体最大字节数，更大的请求体只尝试一次
+const retryBodyLimit = 1 << 20
+
+// Options 代理选项
+type Options struct {
+	// 路由名称，用于日志与监控指标
+	Name string
+	// 转发前从路径中去掉的前缀
+	StripPrefix string
+	Transform   Transform
+}
+
+// Proxy 带负载均衡、健康检查与重试的反向代理
+type Proxy struct {
+	// ErrorHandler 转发失败时调用，默认返回502
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	opts        Options
+	backends    []*backend
+	balancer    balancer
+	retries     int
+	maxFails    int32
+	failTimeout time.Duration
+	transport   http.RoundTripper
+	rp          *httputil.ReverseProxy
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// New 根据上游配置创建反向代理，配置了主动健康检查时在后台定期检查，使用完毕后须调用Close
+func New(conf config.Upstream, opts Options) (*Proxy, error) {
+	if len(conf.Targets) == 0 {
+		return nil, errors.New("proxy: upstream has no targets")
+	}
+	p := &Proxy{
+		retries:     conf.Retries,
+		maxFails:    int32(utils.If(conf.HealthCheck.MaxFails == 0, 3, conf.HealthCheck.MaxFails).(int)),
+		failTimeout: utils.If(conf.HealthCheck.FailTimeout <= 0, 10*time.Second, conf.HealthCheck.FailTimeout*time.Second).(time.Duration),
+		opts:        opts,
+		stop:        make(chan struct{}),
+	}
+	for _, target := range conf.Targets {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: invalid target %q: %v", target, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("proxy: invalid target %q", target)
+		}
+		p.backends = append(p.backends, &backend{url: u, healthy: 1})
+	}
+	b, err := newBalancer(conf.Balance, conf.HashKey, p.backends)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %v", err)
+	}
+	p.balancer = b
+
+	dialer := &net.Dialer{
+		Timeout:   utils.If(conf.DialTimeout <= 0, 10*time.Second, conf.DialTimeout*time.Second).(time.Duration),
+		KeepAlive: 30 * time.Second,
+	}
+	p.transport = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConnsPerHost:   utils.If(conf.MaxIdleConnsPerHost <= 0, 32, conf.MaxIdleConnsPerHost).(int),
+		MaxConnsPerHost:       conf.MaxConnsPerHost,
+		IdleConnTimeout:       utils.If(conf.IdleConnTimeout <= 0, 90*time.Second, conf.IdleConnTimeout*time.Second).(time.Duration),
+		ResponseHeaderTimeout: conf.ResponseTimeout * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	p.rp = &httputil.ReverseProxy{
+		Director:       p.direct,
+		Transport:      &retryTransport{p: p},
+		FlushInterval:  -1,
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if p.ErrorHandler != nil {
+				p.ErrorHandler(w, r, err)
+				return
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+
+	if conf.HealthCheck.Path != "" {
+		go p.check(conf.HealthCheck)
+	}
+	return p, nil
+}
+
+// ServeHTTP 转发请求
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.rp.ServeHTTP(w, r)
+}
+
+// Close 停止主动健康检查并关闭空闲连接
+func (p *Proxy) Close() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+		if t, ok := p.transport.(*http.Transport); ok {
+			t.CloseIdleConnections()
+		}
+	})
+}
+
+/*
+去掉路径前缀并传递追踪请求头，上游地址在每次尝试时由retryTransport选择
+*/
+func (p *Proxy) direct(req *http.Request) {
+	if p.opts.StripPrefix != "" {
+		req.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, p.opts.StripPrefix), "/")
+		req.URL.RawPath = ""
+	}
+	for name, value := range ctxkit.Trace(req.Context()) {
+		if value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	if req.Header.Get(constant.X_REQUEST_ID) == "" {
+		req.Header.Set(constant.X_REQUEST_ID, utils.NewRequestID())
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		// 与httputil.NewSingleHostReverseProxy一致，不使用Go默认的User-Agent
+		req.Header.Set("User-Agent", "")
+	}
+}
+
+// retryTransport 为每次尝试选择上游，记录被动健康状态，失败时对幂等请求换一个上游重试
+type retryTransport struct {
+	p *Proxy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := t.p
+	if p.opts.Transform.Request != nil {
+		if err := p.opts.Transform.Request(req); err != nil {
+			return nil, &TransformError{Err: err}
+		}
+	}
+	attempts := 1
+	if p.retries > 0 && retryable(req) && rewindable(req) {
+		attempts += p.retries
+	}
+
+	tried := make(map[*backend]bool)
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		b := p.balancer.pick(req, p.backends, tried)
+		if b == nil {
+			break
+		}
+		tried[b] = true
+
+		out := req.Clone(req.Context())
+		if i > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				lastErr = err
+				break
+			}
+			out.Body = body
+		}
+		out.URL.Scheme = b.url.Scheme
+		out.URL.Host = b.url.Host
+		if b.url.Path != "" && b.url.Path != "/" {
+			out.URL.Path = strings.TrimSuffix(b.url.Path, "/") + "/" + strings.TrimPrefix(out.URL.Path, "/")
+			out.URL.RawPath = ""
+		}
+
+		atomic.AddInt64(&b.inflight, 1)
+		start := time.Now()
+		resp, err := p.transport.RoundTrip(out)
+		p.observe(out, b, i+1, resp, err, time.Since(start))
+		if err != nil {
+			atomic.AddInt64(&b.inflight, -1)
+			// 客户端取消不计为上游故障
+			if req.Context().Err() != nil {
+				return nil, err
+			}
+			p.fail(b)
+			lastErr = err
+			continue
+		}
+		if isUpstreamError(resp.StatusCode) {
+			p.fail(b)
+			if i < attempts-1 {
+				io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+				atomic.AddInt64(&b.inflight, -1)
+				lastErr = fmt.Errorf("upstream %s returned %d", b.url.Host, resp.StatusCode)
+				continue
+			}
+		} else {
+			p.succeed(b)
+		}
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			// 协议升级后的连接由ReverseProxy直接接管，响应体不能被包装
+			atomic.AddInt64(&b.inflight, -1)
+			return resp, nil
+		}
+		resp.Body = &trackedBody{ReadCloser: resp.Body, b: b}
+		return resp, nil
+	}
+	if lastErr == nil {
+		lastErr = ErrNoUpstream
+	}
+	return nil, lastErr
+}
+
+/*
+每次尝试都记录日志与监控指标
+*/
+func (p *Proxy) observe(req *http.Request, b *backend, attempt int, resp *http.Response, err error, elapsed time.Duration) {
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	upstreamRequests.WithLabelValues(p.opts.Name, b.url.Host, code).Inc()
+	upstreamDuration.WithLabelValues(p.opts.Name, b.url.Host).Observe(elapsed.Seconds())
+
+	entry := ctxkit.Logger(req.Context()).WithFields(logrus.Fields{
+		"upstream":  b.url.Host,
+		"attempt":   attempt,
+		"proc_time": elapsed.Seconds(),
+	})
+	if err != nil {
+		entry.Warnf("proxy %s %s failed: %s", req.Method, req.URL.Path, err)
+		return
+	}
+	entry.Infof("proxy %s %s %d", req.Method, req.URL.Path, resp.StatusCode)
+}
+
+/*
+改写响应，并把请求ID返回给客户端
+*/
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	if resp.Header.Get(constant.X_REQUEST_ID) == "" {
+		resp.Header.Set(constant.X_REQUEST_ID, resp.Request.Header.Get(constant.X_REQUEST_ID))
+	}
+	if p.opts.Transform.Response != nil {
+		if err := p.opts.Transform.Response(resp); err != nil {
+			return &TransformError{Err: err}
+		}
+	}
+	return nil
+}
+
+func (p *Proxy) fail(b *backend) {
+	if p.maxFails < 0 {
+		return
+	}
+	if atomic.AddInt32(&b.fails, 1) >= p.maxFails {
+		atomic.StoreInt32(&b.fails, 0)
+		atomic.StoreInt64(&b.downUntil, time.Now().Add(p.failTimeout).UnixNano())
+	}
+}
+
+func (p *Proxy) succeed(b *backend) {
+	atomic.StoreInt32(&b.fails, 0)
+}
+
+/*
+主动健康检查
+*/
+func (p *Proxy) check(hc config.HealthCheck) {
+	interval := utils.If(hc.Interval <= 0, 10*time.Second, hc.Interval*time.Second).(time.Duration)
+	client := &http.Client{
+		Transport: p.transport,
+		Timeout:   utils.If(hc.Timeout <= 0, 2*time.Second, hc.Timeout*time.Second).(time.Duration),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, b := range p.backends {
+			u := *b.url
+			u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(hc.Path, "/")
+			healthy := int32(0)
+			if resp, err := client.Get(u.String()); err == nil {
+				io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode < 400 {
+					healthy = 1
+				}
+			}
+			atomic.StoreInt32(&b.healthy, healthy)
+		}
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// trackedBody 响应体读完关闭后才算请求结束，leastConn据此统计连接数
+type trackedBody struct {
+	io.ReadCloser
+	b    *backend
+	once sync.Once
+}
+
+func (t *trackedBody) Close() error {
+	t.once.Do(func() {
+		atomic.AddInt64(&t.b.inflight, -1)
+	})
+	return t.ReadCloser.Close()
+}
+
+/*
+只有幂等请求可以安全重试
+*/
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+/*
+请求体可以在重试时重新读取。没有GetBody时缓存不超过retryBodyLimit字节的请求体并设置GetBody，
+超过时把已读取的部分接回请求体，只尝试一次
+*/
+func rewindable(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return true
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(req.Body, retryBodyLimit+1))
+	if err != nil || len(buf) > retryBodyLimit {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), req.Body), req.Body}
+		return false
+	}
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(buf)), nil
+	}
+	return true
+}
+
+func isUpstreamError(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
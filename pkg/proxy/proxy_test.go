@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+)
+
+// received 上游收到的请求
+type received struct {
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// newEchoUpstream 以JSON返回收到的请求
+func newEchoUpstream() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(received{Path: r.URL.Path, Header: r.Header, Body: string(body)})
+	}))
+}
+
+// 有请求体的幂等请求重试时重新发送完整的请求体
+func TestRetryWithBody(t *testing.T) {
+	var failed, echoed int32
+	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		atomic.AddInt32(&failed, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer fail.Close()
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		atomic.AddInt32(&echoed, 1)
+		w.Write(body)
+	}))
+	defer echo.Close()
+
+	large := strings.Repeat("a", retryBodyLimit+1)
+	cases := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		echoed int32
+	}{
+		{"put", "PUT", "hello", http.StatusOK, 1},
+		{"delete without body", "DELETE", "", http.StatusOK, 1},
+		{"post not retried", "POST", "hello", http.StatusServiceUnavailable, 0},
+		{"body too large", "PUT", large, http.StatusServiceUnavailable, 0},
+	}
+	for _, c := range cases {
+		atomic.StoreInt32(&failed, 0)
+		atomic.StoreInt32(&echoed, 0)
+		// 每次新建代理，轮询总是先选择失败的上游
+		p, err := New(config.Upstream{Targets: []string{fail.URL, echo.URL}, Retries: 1,
+			HealthCheck: config.HealthCheck{MaxFails: -1}}, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(c.method, "/", strings.NewReader(c.body)))
+		p.Close()
+		if w.Code != c.status || atomic.LoadInt32(&failed) != 1 || atomic.LoadInt32(&echoed) != c.echoed {
+			t.Errorf("%s: got %d, failed %d, echoed %d", c.name, w.Code, failed, echoed)
+			continue
+		}
+		if c.echoed == 1 && w.Body.String() != c.body {
+			t.Errorf("%s: upstream got %d bytes, want %d", c.name, w.Body.Len(), len(c.body))
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	upstream := newEchoUpstream()
+	defer upstream.Close()
+	p, err := New(config.Upstream{Targets: []string{upstream.URL + "/base"}}, Options{
+		StripPrefix: "/api",
+		Transform: Transform{
+			Request: func(req *http.Request) error {
+				if req.Header.Get("X-Reject") != "" {
+					return errors.New("rejected")
+				}
+				req.URL.Path += "/v2"
+				req.Header.Set("X-Tenant", "t1")
+				SetRequestBody(req, []byte("rewritten"))
+				return nil
+			},
+			Response: func(resp *http.Response) error {
+				body, err := ReadResponseBody(resp)
+				if err != nil {
+					return err
+				}
+				var got received
+				if err := json.Unmarshal(body, &got); err != nil {
+					return err
+				}
+				resp.StatusCode = http.StatusAccepted
+				resp.Header.Set("X-Transformed", "1")
+				SetResponseBody(resp, []byte(got.Path+" "+got.Header.Get("X-Tenant")+" "+got.Body))
+				return nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	var handlerErr error
+	p.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		handlerErr = err
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("POST", "/api/users", strings.NewReader("original")))
+	if w.Code != http.StatusAccepted || w.Header().Get("X-Transformed") != "1" {
+		t.Errorf("got %d %v", w.Code, w.Header())
+	}
+	if want := "/base/users/v2 t1 rewritten"; w.Body.String() != want {
+		t.Errorf("got %q, want %q", w.Body, want)
+	}
+	if w.Header().Get("Content-Length") != "27" {
+		t.Errorf("Content-Length %q", w.Header().Get("Content-Length"))
+	}
+
+	// 改写请求失败时不转发
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	req.Header.Set("X-Reject", "1")
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	var te *TransformError
+	if w.Code != http.StatusInternalServerError || !errors.As(handlerErr, &te) || te.Err.Error() != "rejected" {
+		t.Errorf("got %d %v", w.Code, handlerErr)
+	}
+}
+
+// 框架上下文中的追踪请求头与请求ID传给上游，没有请求ID时生成一个
+func TestTracePropagation(t *testing.T) {
+	upstream := newEchoUpstream()
+	defer upstream.Close()
+	p, err := New(config.Upstream{Targets: []string{upstream.URL}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	send := func(header map[string]string) received {
+		req := httptest.NewRequest("GET", "/", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		req = req.WithContext(ctxkit.WithTrace(req.Context(), map[string]string{"X-B3-Traceid": "trace-1", "X-B3-Spanid": ""}))
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, req)
+		var got received
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("got %d %s", w.Code, w.Body)
+		}
+		return got
+	}
+
+	got := send(map[string]string{constant.X_REQUEST_ID: "req-1", "X-B3-Traceid": "stale"})
+	if got.Header.Get("X-B3-Traceid") != "trace-1" || got.Header.Get(constant.X_REQUEST_ID) != "req-1" {
+		t.Errorf("headers %v", got.Header)
+	}
+	if _, ok := got.Header["X-B3-Spanid"]; ok {
+		t.Errorf("empty trace header forwarded: %v", got.Header)
+	}
+	// 不使用Go默认的User-Agent
+	if got.Header.Get("User-Agent") != "" {
+		t.Errorf("User-Agent %q", got.Header.Get("User-Agent"))
+	}
+
+	first := send(nil).Header.Get(constant.X_REQUEST_ID)
+	second := send(nil).Header.Get(constant.X_REQUEST_ID)
+	if first == "" || first == second {
+		t.Errorf("generated request ids %q and %q", first, second)
+	}
+}
@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// Transform 改写转发的请求与返回的响应，req.Context()与resp.Request.Context()中带有框架上下文
+type Transform struct {
+	// Request 转发前改写请求的header、path与body，返回错误时不再转发
+	Request func(req *http.Request) error
+	// Response 返回客户端前改写响应的状态码、header与body
+	Response func(resp *http.Response) error
+}
+
+// TransformError 改写请求或响应失败
+type TransformError struct {
+	Err error
+}
+
+func (e *TransformError) Error() string {
+	return "proxy: transform: " + e.Err.Error()
+}
+
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
+// SetRequestBody 替换请求体并更新Content-Length
+func SetRequestBody(req *http.Request, body []byte) {
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	req.GetBody = nil
+}
+
+// ReadResponseBody 读取响应体，读取后响应体仍可被转发
+func ReadResponseBody(resp *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, err
+}
+
+// SetResponseBody 替换响应体并更新Content-Length，原响应体会被关闭。
+// 上游返回了压缩内容时读到的是压缩后的字节，改写后须自行处理Content-Encoding
+func SetResponseBody(resp *http.Response, body []byte) {
+	if resp.Body != nil {
+		resp.Body.Close()
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.TransferEncoding = nil
+}
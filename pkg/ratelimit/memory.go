@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval 内存存储清理过期计数的间隔
+const sweepInterval = time.Minute
+
+// MemoryStore 进程内存储，多实例部署时每个实例单独计数
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+// entry 单个key的计数，expire之后配额已完全恢复，可以删除
+type entry struct {
+	// tokenBucket
+	tokens float64
+	last   time.Time
+	// slidingWindow
+	window      int64
+	prev, count int
+
+	expire time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*entry), lastSweep: time.Now()}
+}
+
+// Take 实现Store
+func (s *MemoryStore) Take(_ context.Context, key string, p Policy) (Result, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &entry{tokens: float64(p.Burst), last: now}
+		s.entries[key] = e
+	}
+	if p.Algorithm == SlidingWindow {
+		return e.slidingWindow(now, p), nil
+	}
+	return e.tokenBucket(now, p), nil
+}
+
+/*
+按Limit/Window的速率补充令牌，每个请求消耗一个
+*/
+func (e *entry) tokenBucket(now time.Time, p Policy) Result {
+	rate := float64(p.Limit) / p.Window.Seconds()
+	e.tokens = math.Min(float64(p.Burst), e.tokens+now.Sub(e.last).Seconds()*rate)
+	e.last = now
+
+	allowed := e.tokens >= 1
+	if allowed {
+		e.tokens--
+	}
+	r := tokenBucketResult(p, allowed, e.tokens)
+	e.expire = now.Add(r.Reset)
+	return r
+}
+
+/*
+滑动窗口计数：当前窗口的计数加上前一窗口按剩余比例折算的计数
+*/
+func (e *entry) slidingWindow(now time.Time, p Policy) Result {
+	window, elapsed := windowOf(now, p)
+	switch {
+	case window == e.window+1:
+		e.prev, e.count = e.count, 0
+	case window != e.window:
+		e.prev, e.count = 0, 0
+	}
+	e.window = window
+
+	allowed := float64(e.prev)*weightOf(elapsed, p)+float64(e.count)+1 <= float64(p.Limit)
+	if allowed {
+		e.count++
+	}
+	r := slidingWindowResult(p, allowed, elapsed, e.prev, e.count)
+	e.expire = now.Add(r.Reset)
+	return r
+}
+
+/*
+删除配额已完全恢复的key，避免内存随key的数量增长
+*/
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.entries {
+		if now.After(e.expire) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+func tokenBucketResult(p Policy, allowed bool, tokens float64) Result {
+	rate := float64(p.Limit) / p.Window.Seconds()
+	r := Result{
+		Allowed:   allowed,
+		Limit:     p.capacity(),
+		Remaining: int(tokens),
+		Reset:     seconds((float64(p.Burst) - tokens) / rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return r
+}
+
+// windowOf 返回当前窗口的序号与窗口内已经过的时间
+func windowOf(now time.Time, p Policy) (int64, time.Duration) {
+	window := now.UnixNano() / int64(p.Window)
+	return window, time.Duration(now.UnixNano() - window*int64(p.Window))
+}
+
+// weightOf 前一窗口的计数在当前时刻的折算比例
+func weightOf(elapsed time.Duration, p Policy) float64 {
+	return 1 - float64(elapsed)/float64(p.Window)
+}
+
+func slidingWindowResult(p Policy, allowed bool, elapsed time.Duration, prev, count int) Result {
+	used := float64(prev)*weightOf(elapsed, p) + float64(count)
+	r := Result{
+		Allowed:   allowed,
+		Limit:     p.capacity(),
+		Remaining: int(math.Max(0, float64(p.Limit)-math.Ceil(used))),
+		Reset:     p.Window - elapsed,
+	}
+	if count > 0 {
+		r.Reset += p.Window
+	}
+	switch {
+	case allowed:
+	case count+1 > p.Limit || prev == 0:
+		r.RetryAfter = p.Window - elapsed
+	default:
+		// 前一窗口的折算计数下降到足够时可以再次请求
+		need := float64(p.Window) * (1 - float64(p.Limit-count-1)/float64(prev))
+		r.RetryAfter = time.Duration(need) - elapsed
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		in      Policy
+		want    Policy
+		wantErr bool
+	}{
+		{Policy{Limit: 10}, Policy{Algorithm: TokenBucket, Limit: 10, Window: time.Second, Burst: 10}, false},
+		{Policy{Algorithm: SlidingWindow, Limit: 5, Window: time.Minute, Burst: 1}, Policy{Algorithm: SlidingWindow, Limit: 5, Window: time.Minute, Burst: 1}, false},
+		{Policy{Algorithm: "leaky", Limit: 1}, Policy{}, true},
+		{Policy{Limit: 0}, Policy{}, true},
+		{Policy{Limit: 1, Window: time.Microsecond}, Policy{}, true},
+	}
+	for _, c := range cases {
+		p := c.in
+		err := p.Validate()
+		if (err != nil) != c.wantErr {
+			t.Errorf("%+v: err = %v", c.in, err)
+			continue
+		}
+		if !c.wantErr && p != c.want {
+			t.Errorf("%+v: got %+v, want %+v", c.in, p, c.want)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	p := Policy{Algorithm: TokenBucket, Limit: 2, Window: time.Second, Burst: 2}
+	start := time.Unix(1000, 0)
+	e := &entry{tokens: float64(p.Burst), last: start}
+	steps := []struct {
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		reset      time.Duration
+		retryAfter time.Duration
+	}{
+		{0, true, 1, 500 * time.Millisecond, 0},
+		{0, true, 0, time.Second, 0},
+		{0, false, 0, time.Second, 500 * time.Millisecond},
+		{250 * time.Millisecond, false, 0, 750 * time.Millisecond, 250 * time.Millisecond},
+		{500 * time.Millisecond, true, 0, time.Second, 0},
+		// 空闲后令牌不超过容量
+		{time.Hour, true, 1, 500 * time.Millisecond, 0},
+	}
+	for i, s := range steps {
+		r := e.tokenBucket(start.Add(s.at), p)
+		if r.Allowed != s.allowed || r.Limit != 2 || r.Remaining != s.remaining || r.Reset != s.reset || r.RetryAfter != s.retryAfter {
+			t.Errorf("step %d: got %+v", i, r)
+		}
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	p := Policy{Algorithm: SlidingWindow, Limit: 2, Window: time.Second}
+	start := time.Unix(1000, 0)
+	e := &entry{}
+	steps := []struct {
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{0, true, 1, 0},
+		{100 * time.Millisecond, true, 0, 0},
+		{200 * time.Millisecond, false, 0, 800 * time.Millisecond},
+		// 前一窗口的2次按剩余一半折算为1次
+		{1500 * time.Millisecond, true, 0, 0},
+		// 折算计数降到0时才可以再次请求
+		{1600 * time.Millisecond, false, 0, 400 * time.Millisecond},
+		// 跳过一个窗口后计数清零
+		{3100 * time.Millisecond, true, 1, 0},
+	}
+	for i, s := range steps {
+		r := e.slidingWindow(start.Add(s.at), p)
+		if r.Allowed != s.allowed || r.Limit != 2 || r.Remaining != s.remaining || r.RetryAfter != s.retryAfter {
+			t.Errorf("step %d: got %+v", i, r)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	cases := []struct {
+		key     string
+		policy  Policy
+		allowed bool
+	}{
+		{"a", Policy{Algorithm: TokenBucket, Limit: 1, Window: time.Hour, Burst: 1}, true},
+		{"a", Policy{Algorithm: TokenBucket, Limit: 1, Window: time.Hour, Burst: 1}, false},
+		{"b", Policy{Algorithm: TokenBucket, Limit: 1, Window: time.Hour, Burst: 1}, true},
+		{"c", Policy{Algorithm: SlidingWindow, Limit: 1, Window: time.Hour}, true},
+		{"c", Policy{Algorithm: SlidingWindow, Limit: 1, Window: time.Hour}, false},
+	}
+	for i, c := range cases {
+		r, err := s.Take(ctx, c.key, c.policy)
+		if err != nil || r.Allowed != c.allowed {
+			t.Errorf("case %d (%s): got %+v %v", i, c.key, r, err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	TokenBucket   = "tokenBucket"
+	SlidingWindow = "slidingWindow"
+)
+
+// Policy 限流策略
+type Policy struct {
+	// 算法：TokenBucket（默认）、SlidingWindow
+	Algorithm string
+	// Window内允许的请求数
+	Limit  int
+	Window time.Duration
+	// 令牌桶容量，默认等于Limit
+	Burst int
+}
+
+// Validate 检查策略并填充默认值
+func (p *Policy) Validate() error {
+	switch p.Algorithm {
+	case "":
+		p.Algorithm = TokenBucket
+	case TokenBucket, SlidingWindow:
+	default:
+		return fmt.Errorf("ratelimit: unknown algorithm %q", p.Algorithm)
+	}
+	if p.Limit <= 0 {
+		return fmt.Errorf("ratelimit: limit must be positive")
+	}
+	if p.Window <= 0 {
+		p.Window = time.Second
+	} else if p.Window < time.Millisecond {
+		return fmt.Errorf("ratelimit: window must be at least 1ms")
+	}
+	if p.Burst <= 0 {
+		p.Burst = p.Limit
+	}
+	return nil
+}
+
+// capacity 令牌桶容量或窗口内的请求数，即X-RateLimit-Limit
+func (p Policy) capacity() int {
+	if p.Algorithm == TokenBucket {
+		return p.Burst
+	}
+	return p.Limit
+}
+
+// Result 一次计数的结果
+type Result struct {
+	Allowed bool
+	// 配额上限与剩余配额
+	Limit     int
+	Remaining int
+	// 配额完全恢复需要的时间
+	Reset time.Duration
+	// 被拒绝时距下一次可以请求的时间
+	RetryAfter time.Duration
+}
+
+// Store 限流计数的存储，实现须保证同一个key的并发计数是原子的
+type Store interface {
+	// Take 在key上消耗一次配额
+	Take(ctx context.Context, key string, p Policy) (Result, error)
+}
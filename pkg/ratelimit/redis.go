@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+// 令牌桶：KEYS[1]保存令牌数与上次补充的时间（毫秒），过期时间为令牌补满所需的时间
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil or last == nil then
+	tokens = burst
+	last = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - last) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'last', now)
+redis.call('PEXPIRE', KEYS[1], math.max(1, math.ceil((burst - tokens) / rate)))
+return {allowed, tostring(tokens)}
+`)
+
+// 滑动窗口：KEYS[1]为当前窗口的计数，KEYS[2]为前一窗口的计数
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local weight = tonumber(ARGV[2])
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+local allowed = 0
+if prev * weight + count + 1 <= limit then
+	count = redis.call('INCR', KEYS[1])
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+	allowed = 1
+end
+return {allowed, prev, count}
+`)
+
+// RedisStore 基于redis的存储，多实例共享计数。计数由lua脚本原子完成，使用各实例的本地时间
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore prefix为所有key的前缀，默认"gowb:ratelimit:"
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "gowb:ratelimit:"
+	}
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Take 实现Store
+func (s *RedisStore) Take(ctx context.Context, key string, p Policy) (Result, error) {
+	client := s.client
+	if c, ok := client.(*redis.Client); ok {
+		client = c.WithContext(ctx)
+	}
+	// 同一个限流键的所有key使用相同的hash tag，兼容redis集群
+	key = s.prefix + "{" + key + "}"
+	now := time.Now()
+	if p.Algorithm == SlidingWindow {
+		return s.slidingWindow(client, key, now, p)
+	}
+	return s.tokenBucket(client, key, now, p)
+}
+
+func (s *RedisStore) tokenBucket(client redis.UniversalClient, key string, now time.Time, p Policy) (Result, error) {
+	rate := float64(p.Limit) / float64(p.Window/time.Millisecond)
+	v, err := tokenBucketScript.Run(client, []string{key},
+		strconv.FormatFloat(rate, 'f', -1, 64), p.Burst, now.UnixNano()/int64(time.Millisecond)).Result()
+	if err != nil {
+		return Result{}, err
+	}
+	values, ok := v.([]interface{})
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected script result %v", v)
+	}
+	allowed, _ := values[0].(int64)
+	tokens, err := strconv.ParseFloat(fmt.Sprint(values[1]), 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return tokenBucketResult(p, allowed == 1, tokens), nil
+}
+
+func (s *RedisStore) slidingWindow(client redis.UniversalClient, key string, now time.Time, p Policy) (Result, error) {
+	window, elapsed := windowOf(now, p)
+	keys := []string{key + ":" + strconv.FormatInt(window, 10), key + ":" + strconv.FormatInt(window-1, 10)}
+	v, err := slidingWindowScript.Run(client, keys,
+		p.Limit, strconv.FormatFloat(weightOf(elapsed, p), 'f', -1, 64), int64(2*p.Window/time.Millisecond)).Result()
+	if err != nil {
+		return Result{}, err
+	}
+	values, ok := v.([]interface{})
+	if !ok || len(values) != 3 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected script result %v", v)
+	}
+	allowed, _ := values[0].(int64)
+	prev, _ := values[1].(int64)
+	count, _ := values[2].(int64)
+	return slidingWindowResult(p, allowed == 1, elapsed, int(prev), int(count)), nil
+}
@@ -0,0 +1,209 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+)
+
+// Format 流式响应格式
+type Format string
+
+const (
+	// SSE Server-Sent Events，text/event-stream
+	SSE Format = "sse"
+	// NDJSON 每行一个JSON对象，application/x-ndjson
+	NDJSON Format = "ndjson"
+	// Chunked 原始字节，使用chunked传输编码
+	Chunked Format = "chunked"
+)
+
+// ErrClosed 客户端已断开连接
+var ErrClosed = errors.New("stream: client disconnected")
+
+// Writer 流式响应写入器，每次写入后立即flush，可并发使用。
+// 响应头在第一次写入时发送，在此之前handler仍可通过Header添加自定义响应头
+type Writer struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	format  Format
+	mu      sync.Mutex
+	started bool
+	err     error
+}
+
+// NewWriter 创建流式响应写入器，ctx结束（通常是客户端断开）后所有写入返回ErrClosed
+func NewWriter(ctx context.Context, w http.ResponseWriter, format Format) *Writer {
+	if format == "" {
+		format = SSE
+	}
+	return &Writer{ctx: ctx, w: w, format: format}
+}
+
+// Format 返回流式响应格式
+func (w *Writer) Format() Format {
+	return w.format
+}
+
+// Header 返回响应头，第一次写入之后的修改不再生效
+func (w *Writer) Header() http.Header {
+	return w.w.Header()
+}
+
+// Done 客户端断开连接后关闭
+func (w *Writer) Done() <-chan struct{} {
+	return w.ctx.Done()
+}
+
+// Started 是否已经发送了响应头
+func (w *Writer) Started() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.started
+}
+
+// Err 返回第一次写入失败的原因
+func (w *Writer) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Event 发送一个事件。SSE格式下event为事件名，data为字符串时原样发送，否则编码为JSON；
+// 其他格式忽略事件名，等同于Send
+func (w *Writer) Event(event string, data interface{}) error {
+	if w.format != SSE {
+		return w.Send(data)
+	}
+	var buf bytes.Buffer
+	if err := sse.Encode(&buf, sse.Event{Event: event, Data: data}); err != nil {
+		return err
+	}
+	return w.write(buf.Bytes())
+}
+
+// Send 发送一条数据。SSE格式下为不带事件名的message事件，NDJSON格式下为一行JSON，
+// Chunked格式下[]byte与string原样发送，其他类型编码为JSON
+func (w *Writer) Send(data interface{}) error {
+	switch w.format {
+	case SSE:
+		return w.Event("", data)
+	case Chunked:
+		switch v := data.(type) {
+		case []byte:
+			return w.write(v)
+		case string:
+			return w.write([]byte(v))
+		}
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return w.write(append(b, '\n'))
+}
+
+// Write 原样写入字节并flush，实现io.Writer，可配合io.Copy等使用
+func (w *Writer) Write(p []byte) (int, error) {
+	if err := w.write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Heartbeat 发送心跳保持连接。SSE格式下为注释行，NDJSON格式下为空行，Chunked格式下只flush
+func (w *Writer) Heartbeat() error {
+	switch w.format {
+	case SSE:
+		return w.write([]byte(":ping\n\n"))
+	case NDJSON:
+		return w.write([]byte("\n"))
+	default:
+		return w.write(nil)
+	}
+}
+
+// KeepAlive 在后台每隔interval发送一次心跳，直到ctx结束或调用返回的stop函数。
+// stop会等待后台goroutine退出，须在handler返回前调用
+func (w *Writer) KeepAlive(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	quit := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if w.Heartbeat() != nil {
+					return
+				}
+			case <-quit:
+				return
+			case <-w.ctx.Done():
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(quit)
+			<-exited
+		})
+	}
+}
+
+func (w *Writer) write(p []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	if w.ctx.Err() != nil {
+		w.err = ErrClosed
+		return w.err
+	}
+	if !w.started {
+		w.started = true
+		w.writeHeader()
+	}
+	if len(p) > 0 {
+		if _, err := w.w.Write(p); err != nil {
+			w.err = err
+			return err
+		}
+	}
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func (w *Writer) writeHeader() {
+	h := w.w.Header()
+	switch w.format {
+	case SSE:
+		h.Set("Content-Type", sse.ContentType)
+		h.Set("Connection", "keep-alive")
+	case NDJSON:
+		h.Set("Content-Type", "application/x-ndjson")
+	default:
+		if h.Get("Content-Type") == "" {
+			h.Set("Content-Type", "application/octet-stream")
+		}
+	}
+	h.Set("Cache-Control", "no-cache")
+	// 关闭nginx等反向代理的响应缓冲
+	h.Set("X-Accel-Buffering", "no")
+	w.w.WriteHeader(http.StatusOK)
+}
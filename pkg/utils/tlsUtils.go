@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+)
+
+// NewTLSConfig 根据配置创建tls.Config，证书与客户端CA文件变化后自动重新加载
+func NewTLSConfig(conf config.TLS) (*tls.Config, error) {
+	if conf.CertFile == "" || conf.KeyFile == "" {
+		return nil, errors.New("tls certFile and keyFile are required")
+	}
+	minVersion, err := tlsVersion(conf.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &certReloader{
+		conf:     conf,
+		interval: If(conf.ReloadInterval <= 0, 10*time.Second, conf.ReloadInterval*time.Second).(time.Duration),
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	clientAuth := tls.NoClientCert
+	if conf.ClientCAFile != "" {
+		clientAuth = tls.RequireAndVerifyClientCert
+		if conf.ClientAuth == "optional" {
+			clientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	base := &tls.Config{
+		MinVersion:     minVersion,
+		ClientAuth:     clientAuth,
+		GetCertificate: r.getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.maybeReload()
+		c := base.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = r.clientCAs()
+		return c, nil
+	}
+	return base, nil
+}
+
+// ClientSubject 返回已校验的客户端证书主题
+func ClientSubject(state *tls.ConnectionState) (pkix.Name, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return pkix.Name{}, false
+	}
+	return state.VerifiedChains[0][0].Subject, true
+}
+
+// tlsVersion 解析最低TLS版本，默认1.2
+func tlsVersion(v string) (uint16, error) {
+	switch v {
+	case "":
+		return tls.VersionTLS12, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported tls minVersion: %s", v)
+	}
+}
+
+// certReloader 定期检查证书文件的修改时间，变化后重新加载
+type certReloader struct {
+	conf     config.TLS
+	interval time.Duration
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime time.Time
+	checked time.Time
+}
+
+func (r *certReloader) load() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.conf.CertFile, r.conf.KeyFile)
+	if err != nil {
+		return err
+	}
+	var pool *x509.CertPool
+	if r.conf.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(r.conf.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", r.conf.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.pool = pool
+	r.modTime = modTime
+	r.checked = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// latestModTime 证书、私钥与客户端CA文件中最新的修改时间
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range []string{r.conf.CertFile, r.conf.KeyFile, r.conf.ClientCAFile} {
+		if f == "" {
+			continue
+		}
+		fi, err := os.Stat(f)
+		if err != nil {
+			return latest, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// maybeReload 距上次检查超过interval时检查文件是否变化，加载失败时继续使用旧证书
+func (r *certReloader) maybeReload() {
+	r.mu.Lock()
+	if time.Since(r.checked) < r.interval {
+		r.mu.Unlock()
+		return
+	}
+	r.checked = time.Now()
+	last := r.modTime
+	r.mu.Unlock()
+
+	modTime, err := r.latestModTime()
+	if err != nil || !modTime.After(last) {
+		return
+	}
+	if err := r.load(); err != nil {
+		log.Printf("[error] reload tls certificate: %s", err)
+		return
+	}
+	log.Printf("[info] reloaded tls certificate %s", r.conf.CertFile)
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *certReloader) clientCAs() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+)
+
+// nopLogger 丢弃握手失败的日志
+var nopLogger = log.New(ioutil.Discard, "", 0)
+
+// testCA 测试用的CA，签发服务端与客户端证书
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue 签发证书，返回PEM格式的证书与私钥
+func (ca *testCA) issue(t *testing.T, subject pkix.Name, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSVersion(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    uint16
+		wantErr bool
+	}{
+		{"", tls.VersionTLS12, false},
+		{"1.0", tls.VersionTLS10, false},
+		{"1.3", tls.VersionTLS13, false},
+		{"1.4", 0, true},
+	}
+	for _, c := range cases {
+		got, err := tlsVersion(c.in)
+		if (err != nil) != c.wantErr || got != c.want {
+			t.Errorf("%q: got %x %v", c.in, got, err)
+		}
+	}
+}
+
+// 证书文件变化后重新加载，加载失败时继续使用旧证书
+func TestCertReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gowb-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca := newTestCA(t)
+	conf := config.TLS{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+	now := time.Now()
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "v1"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, conf.CertFile, certPEM, now)
+	writeFile(t, conf.KeyFile, keyPEM, now)
+
+	r := &certReloader{conf: conf}
+	if err := r.load(); err != nil {
+		t.Fatal(err)
+	}
+	commonName := func() string {
+		cert, _ := r.getCertificate(nil)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	// 未到检查间隔时不重新加载
+	r.interval = time.Hour
+	certPEM, keyPEM = ca.issue(t, pkix.Name{CommonName: "v2"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, conf.CertFile, certPEM, now.Add(time.Minute))
+	writeFile(t, conf.KeyFile, keyPEM, now.Add(time.Minute))
+	if got := commonName(); got != "v1" {
+		t.Errorf("before interval: got %s", got)
+	}
+
+	r.interval = 0
+	if got := commonName(); got != "v2" {
+		t.Errorf("after change: got %s", got)
+	}
+
+	writeFile(t, conf.KeyFile, []byte("broken"), now.Add(2*time.Minute))
+	if got := commonName(); got != "v2" {
+		t.Errorf("after broken key: got %s", got)
+	}
+}
+
+// mTLS校验通过的客户端证书主题
+func TestClientSubject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gowb-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "server"}, x509.ExtKeyUsageServerAuth)
+	conf := config.TLS{
+		CertFile:     filepath.Join(dir, "cert.pem"),
+		KeyFile:      filepath.Join(dir, "key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	writeFile(t, conf.CertFile, certPEM, time.Now())
+	writeFile(t, conf.KeyFile, keyPEM, time.Now())
+	writeFile(t, conf.ClientCAFile, ca.pem, time.Now())
+
+	clientPEM, clientKeyPEM := ca.issue(t, pkix.Name{CommonName: "alice", Organization: []string{"acme"}}, x509.ExtKeyUsageClientAuth)
+	clientCert, err := tls.X509KeyPair(clientPEM, clientKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	cases := []struct {
+		name       string
+		clientAuth string
+		client     []tls.Certificate
+		want       string
+		wantErr    bool
+	}{
+		{"required", "", []tls.Certificate{clientCert}, "alice/acme", false},
+		{"required without cert", "", nil, "", true},
+		{"optional without cert", "optional", nil, "-", false},
+	}
+	for _, c := range cases {
+		conf.ClientAuth = c.clientAuth
+		tlsConfig, err := NewTLSConfig(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			subject, ok := ClientSubject(r.TLS)
+			if !ok {
+				w.Write([]byte("-"))
+				return
+			}
+			w.Write([]byte(subject.CommonName + "/" + subject.Organization[0]))
+		}))
+		srv.TLS = tlsConfig
+		srv.Config.ErrorLog = nopLogger
+		srv.StartTLS()
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: c.client}}}
+		resp, err := client.Get(srv.URL)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: err = %v", c.name, err)
+		}
+		if err == nil {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != c.want {
+				t.Errorf("%s: got %q, want %q", c.name, body, c.want)
+			}
+		}
+		client.CloseIdleConnections()
+		srv.Close()
+	}
+
+	if _, ok := ClientSubject(nil); ok {
+		t.Error("nil state should have no subject")
+	}
+}
@@ -0,0 +1,131 @@
+package web
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CachePolicy 路由的HTTP缓存策略，作用于GET、HEAD请求的2xx响应，替代全局的no-cache响应头。
+// 输出model.Response时根据编码后的响应体生成ETag，请求的If-None-Match或If-Modified-Since满足时返回304
+//
+//	web.Router{Path: "/regions", Method: "GET", Handler: ListRegions, Cache: &web.CachePolicy{
+//		MaxAge: time.Hour, Public: true, StaleWhileRevalidate: time.Minute,
+//	}}
+type CachePolicy struct {
+	// 客户端的缓存时间
+	MaxAge time.Duration
+	// 共享缓存（CDN、代理）的缓存时间，对应s-maxage，0不设置
+	SharedMaxAge time.Duration
+	// 允许共享缓存保存，否则为private
+	Public bool
+	// 过期后在后台重新验证期间仍可使用旧响应的时间
+	StaleWhileRevalidate time.Duration
+	// 每次使用缓存前须向服务端验证（no-cache），配合ETag可以得到304
+	NoCache bool
+	// 不生成ETag
+	DisableETag bool
+}
+
+// String 生成Cache-Control
+func (p CachePolicy) String() string {
+	parts := []string{"private"}
+	if p.Public {
+		parts[0] = "public"
+	}
+	if p.NoCache {
+		parts = append(parts, "no-cache")
+	}
+	parts = append(parts, "max-age="+seconds(p.MaxAge))
+	if p.SharedMaxAge > 0 {
+		parts = append(parts, "s-maxage="+seconds(p.SharedMaxAge))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		parts = append(parts, "stale-while-revalidate="+seconds(p.StaleWhileRevalidate))
+	}
+	return strings.Join(parts, ", ")
+}
+
+/*
+用缓存策略替换全局NoCache中间件设置的响应头
+*/
+func (p CachePolicy) apply(h http.Header, lastModified time.Time) {
+	h.Set("Cache-Control", p.String())
+	h.Del("Expires")
+	if lastModified.IsZero() {
+		h.Del("Last-Modified")
+	}
+}
+
+func cacheable(method string, status int) bool {
+	return (method == http.MethodGet || method == http.MethodHead) && (status == 0 || status >= 200 && status < 300)
+}
+
+/*
+编码响应并生成ETag，满足条件请求时返回304
+*/
+func respondCached(ctx *gin.Context, _router Router, status int, v interface{}, lastModified time.Time) {
+	contentType, body, err := encodeResponse(ctx.Request, _router.Produces, v)
+	if err != nil {
+		ctx.Status(http.StatusInternalServerError)
+		ctx.Writer.WriteHeaderNow()
+		return
+	}
+	ctx.Writer.Header().Add("Vary", "Accept")
+	writeConditional(ctx, _router, status, contentType, body, lastModified)
+}
+
+func writeConditional(ctx *gin.Context, _router Router, status int, contentType string, body []byte, lastModified time.Time) {
+	h := ctx.Writer.Header()
+	etag := ""
+	if !_router.Cache.DisableETag {
+		etag = newETag(body)
+		h.Set("ETag", etag)
+	}
+	if notModified(ctx.Request, etag, lastModified) {
+		ctx.Status(http.StatusNotModified)
+		ctx.Writer.WriteHeaderNow()
+		return
+	}
+	h.Set("Content-Type", contentType)
+	ctx.Writer.WriteHeader(status)
+	ctx.Writer.Write(body)
+}
+
+func newETag(body []byte) string {
+	h := fnv.New64a()
+	h.Write(body)
+	return fmt.Sprintf(`"%x-%x"`, len(body), h.Sum64())
+}
+
+/*
+If-None-Match优先于If-Modified-Since，ETag使用弱比较
+*/
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/model"
+)
+
+func TestCachePolicyString(t *testing.T) {
+	cases := []struct {
+		policy CachePolicy
+		want   string
+	}{
+		{CachePolicy{}, "private, max-age=0"},
+		{CachePolicy{MaxAge: time.Hour, Public: true}, "public, max-age=3600"},
+		{CachePolicy{NoCache: true}, "private, no-cache, max-age=0"},
+		{CachePolicy{MaxAge: time.Minute, SharedMaxAge: time.Hour, StaleWhileRevalidate: 30 * time.Second, Public: true},
+			"public, max-age=60, s-maxage=3600, stale-while-revalidate=30"},
+	}
+	for _, c := range cases {
+		if got := c.policy.String(); got != c.want {
+			t.Errorf("%+v: got %q, want %q", c.policy, got, c.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	etag := `"3-abc"`
+	cases := []struct {
+		name         string
+		header       map[string]string
+		etag         string
+		lastModified time.Time
+		want         bool
+	}{
+		{"no condition", nil, etag, modified, false},
+		{"etag match", map[string]string{"If-None-Match": etag}, etag, modified, true},
+		{"weak etag match", map[string]string{"If-None-Match": `W/"3-abc"`}, etag, modified, true},
+		{"etag in list", map[string]string{"If-None-Match": `"1-x", "3-abc"`}, etag, modified, true},
+		{"etag star", map[string]string{"If-None-Match": "*"}, etag, modified, true},
+		{"etag mismatch", map[string]string{"If-None-Match": `"1-x"`}, etag, modified, false},
+		{"etag disabled", map[string]string{"If-None-Match": "*"}, "", modified, false},
+		// If-None-Match优先于If-Modified-Since
+		{"etag mismatch ignores ims", map[string]string{"If-None-Match": `"1-x"`, "If-Modified-Since": modified.Format(http.TimeFormat)}, etag, modified, false},
+		{"ims equal", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, etag, modified.Add(500 * time.Millisecond), true},
+		{"ims later", map[string]string{"If-Modified-Since": modified.Add(time.Hour).Format(http.TimeFormat)}, etag, modified, true},
+		{"ims earlier", map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)}, etag, modified, false},
+		{"ims without last modified", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, etag, time.Time{}, false},
+		{"ims invalid", map[string]string{"If-Modified-Since": "yesterday"}, etag, modified, false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		if got := notModified(req, c.etag, c.lastModified); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// 设置了Cache的路由输出缓存策略与ETag，条件请求满足时返回304
+func TestCachePolicyRoute(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	handler := func(ctx context.Context) (model.Response, HttpStatus) {
+		Result(ctx).LastModified(modified)
+		return model.Response{Data: "regions"}, http.StatusOK
+	}
+	failing := func(ctx context.Context) (model.Response, HttpStatus) {
+		return model.Response{}, http.StatusNotFound
+	}
+	policy := &CachePolicy{MaxAge: time.Hour, Public: true}
+	r := newTestEngine(t, config.Config{}, []Router{
+		{Path: "/regions", Method: "GET", Handler: handler, Cache: policy},
+		{Path: "/regions", Method: "POST", Handler: handler, Cache: policy},
+		{Path: "/missing", Method: "GET", Handler: failing, Cache: policy},
+		{Path: "/no-etag", Method: "GET", Handler: handler, Cache: &CachePolicy{MaxAge: time.Hour, DisableETag: true}},
+	})
+
+	w := serve(r, "GET", "/regions", nil, nil)
+	h := w.Header()
+	etag := h.Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || h.Get("Cache-Control") != "public, max-age=3600" || h.Get("Expires") != "" {
+		t.Fatalf("got %d %v", w.Code, h)
+	}
+	if h.Get("Last-Modified") != modified.Format(http.TimeFormat) {
+		t.Errorf("Last-Modified %q", h.Get("Last-Modified"))
+	}
+
+	for _, header := range []map[string]string{
+		{"If-None-Match": etag},
+		{"If-Modified-Since": modified.Format(http.TimeFormat)},
+	} {
+		w := serve(r, "GET", "/regions", nil, header)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+			t.Errorf("%v: got %d %q", header, w.Code, w.Body)
+		}
+	}
+	if w := serve(r, "GET", "/regions", nil, map[string]string{"If-None-Match": `"0-0"`}); w.Code != http.StatusOK {
+		t.Errorf("stale etag: got %d", w.Code)
+	}
+
+	// POST与非2xx响应保持全局的no-cache
+	for _, req := range []struct{ method, path string }{{"POST", "/regions"}, {"GET", "/missing"}} {
+		w := serve(r, req.method, req.path, nil, map[string]string{"If-None-Match": etag})
+		if w.Code == http.StatusNotModified || w.Header().Get("ETag") != "" || w.Header().Get("Expires") == "" {
+			t.Errorf("%s %s: got %d %v", req.method, req.path, w.Code, w.Header())
+		}
+	}
+
+	w = serve(r, "GET", "/no-etag", nil, map[string]string{"If-None-Match": "*"})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != "" {
+		t.Errorf("no-etag: got %d %v", w.Code, w.Header())
+	}
+}
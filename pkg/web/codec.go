@@ -0,0 +1,197 @@
+package web
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/protobuf/proto"
+	"github.com/mj37yhyy/gowb/pkg/model"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v2"
+)
+
+// Codec 响应编码器
+type Codec interface {
+	// ContentType 响应的Content-Type
+	ContentType() string
+	// Marshal 将响应编码为字节
+	Marshal(v interface{}) ([]byte, error)
+}
+
+type codecFunc struct {
+	contentType string
+	marshal     func(v interface{}) ([]byte, error)
+}
+
+func (c codecFunc) ContentType() string {
+	return c.contentType
+}
+
+func (c codecFunc) Marshal(v interface{}) ([]byte, error) {
+	return c.marshal(v)
+}
+
+// NewCodec 由Content-Type与编码函数创建Codec
+func NewCodec(contentType string, marshal func(v interface{}) ([]byte, error)) Codec {
+	return codecFunc{contentType: contentType, marshal: marshal}
+}
+
+// jsonCodec 未协商出格式或编码失败时使用
+var jsonCodec = NewCodec("application/json; charset=utf-8", json.Marshal)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+	// codecOrder 注册顺序，通配符匹配时按此顺序选择
+	codecOrder []string
+)
+
+func init() {
+	xmlCodec := NewCodec("application/xml; charset=utf-8", xml.Marshal)
+	yamlCodec := NewCodec("application/x-yaml; charset=utf-8", yaml.Marshal)
+	msgpackCodec := NewCodec("application/msgpack", marshalMsgPack)
+	protobufCodec := NewCodec("application/x-protobuf", marshalProtoBuf)
+
+	RegisterCodec("application/json", jsonCodec)
+	RegisterCodec("application/xml", xmlCodec)
+	RegisterCodec("text/xml", xmlCodec)
+	RegisterCodec("application/x-yaml", yamlCodec)
+	RegisterCodec("application/yaml", yamlCodec)
+	RegisterCodec("text/yaml", yamlCodec)
+	RegisterCodec("application/msgpack", msgpackCodec)
+	RegisterCodec("application/x-msgpack", msgpackCodec)
+	RegisterCodec("application/x-protobuf", protobufCodec)
+	RegisterCodec("application/protobuf", protobufCodec)
+}
+
+// RegisterCodec 注册响应编码器，mediaType为Accept中使用的媒体类型（如"application/cbor"），重复注册时覆盖
+func RegisterCodec(mediaType string, c Codec) {
+	mediaType = strings.ToLower(mediaType)
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if _, ok := codecs[mediaType]; !ok {
+		codecOrder = append(codecOrder, mediaType)
+	}
+	codecs[mediaType] = c
+}
+
+func lookupCodec(mediaType string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[strings.ToLower(mediaType)]
+	return c, ok
+}
+
+/*
+按Accept请求头选择编码器，q值高者优先。Accept为空、通配或没有可用的编码器时使用路由的默认格式
+*/
+func negotiate(accept, produces string) Codec {
+	def := jsonCodec
+	if c, ok := lookupCodec(produces); ok {
+		def = c
+	}
+
+	type mediaRange struct {
+		typ string
+		q   float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{typ: typ, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	for _, r := range ranges {
+		if r.typ == "*/*" {
+			return def
+		}
+		if strings.HasSuffix(r.typ, "/*") {
+			prefix := strings.TrimSuffix(r.typ, "*")
+			if strings.HasPrefix(strings.ToLower(produces), prefix) {
+				return def
+			}
+			codecsMu.RLock()
+			for _, mt := range codecOrder {
+				if strings.HasPrefix(mt, prefix) {
+					codecsMu.RUnlock()
+					return codecs[mt]
+				}
+			}
+			codecsMu.RUnlock()
+			continue
+		}
+		if c, ok := lookupCodec(r.typ); ok {
+			return c
+		}
+	}
+	return def
+}
+
+/*
+按协商出的格式输出响应，编码失败（如XML不支持map）时退回JSON
+*/
+func respond(ctx *gin.Context, produces string, status int, v interface{}) {
+	writeResponse(ctx.Writer, ctx.Request, produces, status, v)
+}
+
+func writeResponse(w http.ResponseWriter, r *http.Request, produces string, status int, v interface{}) {
+	contentType, body, err := encodeResponse(r, produces, v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h := w.Header()
+	h.Add("Vary", "Accept")
+	h.Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func encodeResponse(r *http.Request, produces string, v interface{}) (string, []byte, error) {
+	c := negotiate(r.Header.Get("Accept"), produces)
+	body, err := c.Marshal(v)
+	if err != nil {
+		c = jsonCodec
+		body, err = c.Marshal(v)
+	}
+	return c.ContentType(), body, err
+}
+
+func marshalMsgPack(v interface{}) ([]byte, error) {
+	var b []byte
+	err := codec.NewEncoderBytes(&b, new(codec.MsgpackHandle)).Encode(v)
+	return b, err
+}
+
+// marshalProtoBuf 响应本身或model.Response.Data为proto.Message时才能编码
+func marshalProtoBuf(v interface{}) ([]byte, error) {
+	if resp, ok := v.(model.Response); ok {
+		v = resp.Data
+	}
+	if msg, ok := v.(proto.Message); ok {
+		return proto.Marshal(msg)
+	}
+	return nil, errors.New("protobuf response requires a proto.Message")
+}
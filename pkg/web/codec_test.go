@@ -0,0 +1,36 @@
+package web
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		accept   string
+		produces string
+		want     string
+	}{
+		{"", "", "application/json; charset=utf-8"},
+		{"", "application/xml", "application/xml; charset=utf-8"},
+		{"*/*", "application/x-yaml", "application/x-yaml; charset=utf-8"},
+		{"application/xml", "", "application/xml; charset=utf-8"},
+		{"text/xml", "", "application/xml; charset=utf-8"},
+		{"application/msgpack", "", "application/msgpack"},
+		{"application/x-protobuf", "", "application/x-protobuf"},
+		{"APPLICATION/YAML", "", "application/x-yaml; charset=utf-8"},
+		// q值高者优先，q=0表示不接受
+		{"application/xml;q=0.5, application/x-yaml", "", "application/x-yaml; charset=utf-8"},
+		{"application/xml;q=0, */*;q=0.1", "", "application/json; charset=utf-8"},
+		// 不支持的类型跳过，都不支持时使用默认格式
+		{"text/html, application/xml;q=0.9", "", "application/xml; charset=utf-8"},
+		{"text/html", "application/xml", "application/xml; charset=utf-8"},
+		{"image/png;q=bad", "", "application/json; charset=utf-8"},
+		// 通配子类型优先匹配路由的默认格式，否则按注册顺序
+		{"application/*", "application/xml", "application/xml; charset=utf-8"},
+		{"application/*", "", "application/json; charset=utf-8"},
+		{"text/*", "", "application/xml; charset=utf-8"},
+	}
+	for _, c := range cases {
+		if got := negotiate(c.accept, c.produces).ContentType(); got != c.want {
+			t.Errorf("negotiate(%q, %q) = %q, want %q", c.accept, c.produces, got, c.want)
+		}
+	}
+}
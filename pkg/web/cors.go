@@ -0,0 +1,78 @@
+package web
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/web/middleware"
+)
+
+// corsPolicy 路径前缀及其跨域处理函数，handle为nil表示不处理跨域
+type corsPolicy struct {
+	prefix string
+	handle gin.HandlerFunc
+}
+
+/*
+创建跨域中间件。预检请求不会匹配到分组内的路由，因此作为全局中间件按请求路径选择策略，
+路径属于设置了CORS的分组时使用最内层分组的策略，否则使用全局策略
+*/
+func newCORS(conf config.CORS, groups []RouterGroup) (gin.HandlerFunc, error) {
+	global, err := corsHandler(conf)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := groupCORS("/", groups)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		return len(policies[i].prefix) > len(policies[j].prefix)
+	})
+
+	return func(ctx *gin.Context) {
+		handle := global
+		p := ctx.Request.URL.Path
+		for _, policy := range policies {
+			if p == policy.prefix || strings.HasPrefix(p, strings.TrimSuffix(policy.prefix, "/")+"/") {
+				handle = policy.handle
+				break
+			}
+		}
+		if handle == nil {
+			ctx.Next()
+			return
+		}
+		handle(ctx)
+	}, nil
+}
+
+func groupCORS(parent string, groups []RouterGroup) ([]corsPolicy, error) {
+	var policies []corsPolicy
+	for _, group := range groups {
+		prefix := path.Join(parent, group.Prefix)
+		if group.CORS != nil {
+			handle, err := corsHandler(*group.CORS)
+			if err != nil {
+				return nil, err
+			}
+			policies = append(policies, corsPolicy{prefix: prefix, handle: handle})
+		}
+		children, err := groupCORS(prefix, group.Groups)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, children...)
+	}
+	return policies, nil
+}
+
+func corsHandler(conf config.CORS) (gin.HandlerFunc, error) {
+	if conf.Disabled {
+		return nil, nil
+	}
+	return middleware.CORS(conf)
+}
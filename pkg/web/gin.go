@@ -1,30 +1,40 @@
 package web
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"github.com/chenjiandongx/ginprom"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/jinzhu/gorm"
+	"github.com/mj37yhyy/gowb/pkg/cache"
 	"github.com/mj37yhyy/gowb/pkg/config"
 	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
 	"github.com/mj37yhyy/gowb/pkg/db"
+	"github.com/mj37yhyy/gowb/pkg/listener"
 	"github.com/mj37yhyy/gowb/pkg/model"
+	"github.com/mj37yhyy/gowb/pkg/proxy"
+	"github.com/mj37yhyy/gowb/pkg/stream"
+	"github.com/mj37yhyy/gowb/pkg/utils"
 	"github.com/mj37yhyy/gowb/pkg/web/middleware"
+	"github.com/mj37yhyy/gowb/pkg/ws"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/swaggo/gin-swagger"
 	"github.com/swaggo/gin-swagger/swaggerFiles"
-	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
-	"unsafe"
 )
 
 type HttpStatus int
@@ -37,47 +47,287 @@ type Router struct {
 	OpenFlatTransaction bool
 	ReverseProxy        bool
 	Director            Director
+	// 输入参数类型，设置后自动解码path、query、header与body并校验，Handler中通过Input(ctx)获取
+	InputType interface{}
+	// 仅作用于该路由的中间件，在全局与分组中间件之后执行
+	Middleware []gin.HandlerFunc
+	// 处理超时时间，超时后取消上下文、回滚事务并返回504
+	Timeout time.Duration
+	// 流式处理函数，设置后替代Handler，输出SSE、NDJSON或chunked字节
+	Stream StreamFunc
+	// 流式响应格式，默认stream.SSE
+	StreamFormat stream.Format
+	// 流式响应与WebSocket的心跳间隔，0使用默认的15秒，负数关闭
+	Heartbeat time.Duration
+	// WebSocket处理函数，设置后替代Handler，握手后通过ws.Conn收发消息，Method须为GET
+	WebSocket WebSocketFunc
+	// 默认的响应格式（媒体类型，如application/xml），请求未通过Accept指定时使用，默认JSON
+	Produces string
+	// 请求体最大字节数，覆盖全局的maxBodySize，负数不限制
+	MaxBodySize int64
+	// 声明式反向代理的上游服务池，设置后替代Handler，Method为空时代理所有方法
+	Upstream *config.Upstream
+	// 转发前从路径中去掉的前缀，仅对Upstream有效
+	StripPrefix string
+	// 改写转发的请求与返回的响应，仅对Upstream有效
+	Transform proxy.Transform
+	// 流量镜像，按比例把请求异步复制到影子上游或Handler并比较结果
+	Mirror *Mirror
+	// 覆盖全局的安全响应头配置，Enabled为false时不输出安全响应头
+	Security *config.Security
+	// HTTP缓存策略，设置后GET、HEAD请求的成功响应可被客户端与CDN缓存，并支持ETag与304
+	Cache *CachePolicy
+	// 服务端响应缓存，设置后GET、HEAD请求的成功响应在服务端缓存，命中时不调用Handler
+	ResponseCache *ResponseCache
+}
+
+// RouterGroup 路由分组，分组内的路由共享路径前缀与中间件，可嵌套
+type RouterGroup struct {
+	Prefix     string
+	Middleware []gin.HandlerFunc
+	Routers    []Router
+	Groups     []RouterGroup
+	// 覆盖全局的跨域策略，作用于分组及其子分组
+	CORS *config.CORS
+}
+
+// Server 可嵌入的http服务，Start不会阻塞
+type Server struct {
+	httpSrv      *http.Server
+	cancel       context.CancelFunc
+	listener     net.Listener
+	routes       *routeState
+	preStopDelay time.Duration
+	listenOpts   listener.Options
+	done         chan struct{}
+	err          error
+}
+
+// routeState 路由处理函数共享的服务级状态
+type routeState struct {
+	health  *health
+	sockets *ws.Hub
+	proxies []*proxy.Proxy
+}
+
+// health 健康检查状态，停机排空时返回未就绪
+type health struct {
+	draining int32
+}
+
+func (h *health) handle(c *gin.Context) {
+	if atomic.LoadInt32(&h.draining) == 1 {
+		c.String(http.StatusServiceUnavailable, "shutting down")
+		return
+	}
+	c.String(http.StatusOK, fmt.Sprintf(time.Now().String()))
 }
 
 func Bootstrap(ctx context.Context) {
-	server := start(ctx)
+	server, err := NewServer(ctx)
+	if err == nil {
+		err = server.Start()
+	}
+	if err != nil {
+		log.Fatalf("listen: %s\n", err)
+	}
 	_signal()
 	_timeout(ctx, server)
 }
 
-func start(c context.Context) *http.Server {
-	conf := c.Value(constant.ConfigKey).(config.Config)
-	routers := c.Value(constant.RoutersKey).([]Router)
+// contextKey NewServer从上下文读取路由与中间件使用的键
+type contextKey int
+
+const (
+	routersKey contextKey = iota
+	groupsKey
+	middlewareKey
+	rateLimitStoreKey
+	cacheStoreKey
+)
+
+// WithRouters 将用户路由放入上下文，供NewServer使用
+func WithRouters(c context.Context, routers []Router) context.Context {
+	return context.WithValue(context.WithValue(c, constant.RoutersKey, routers), routersKey, routers)
+}
+
+// WithGroups 将路由分组放入上下文，供NewServer使用
+func WithGroups(c context.Context, groups []RouterGroup) context.Context {
+	return context.WithValue(c, groupsKey, groups)
+}
+
+// WithMiddleware 将全局中间件放入上下文，供NewServer使用
+func WithMiddleware(c context.Context, mw []gin.HandlerFunc) context.Context {
+	return context.WithValue(context.WithValue(c, constant.MiddlewareKey, mw), middlewareKey, mw)
+}
+
+func routersFrom(c context.Context) []Router {
+	if routers, ok := c.Value(routersKey).([]Router); ok {
+		return routers
+	}
+	routers, _ := c.Value(constant.RoutersKey).([]Router)
+	return routers
+}
+
+func groupsFrom(c context.Context) []RouterGroup {
+	groups, _ := c.Value(groupsKey).([]RouterGroup)
+	return groups
+}
+
+func middlewareFrom(c context.Context) []gin.HandlerFunc {
+	if mw, ok := c.Value(middlewareKey).([]gin.HandlerFunc); ok {
+		return mw
+	}
+	mw, _ := c.Value(constant.MiddlewareKey).([]gin.HandlerFunc)
+	return mw
+}
+
+// NewServer 根据上下文中的配置与路由创建http服务
+func NewServer(c context.Context) (*Server, error) {
+	conf := ctxkit.Config(c)
+	routers := append(append([]Router{}, routersFrom(c)...), proxyRouters(conf)...)
 
 	gin.SetMode(conf.Web.RunMode)
 
-	routersInit := doRouter(c, routers)
-	readTimeout := time.Minute
-	writeTimeout := time.Minute
-	endPoint := fmt.Sprintf(":%d", conf.Web.Port)
-	maxHeaderBytes := 1 << 20
+	store, err := cacheStore(c, conf.Web.Cache)
+	if err != nil {
+		return nil, err
+	}
+	c = ctxkit.WithResponseCache(c, cache.New(store, ""))
 
-	server := &http.Server{
-		Addr:           endPoint,
-		Handler:        routersInit,
-		ReadTimeout:    readTimeout,
-		WriteTimeout:   writeTimeout,
-		MaxHeaderBytes: maxHeaderBytes,
+	rs := &routeState{health: &health{}, sockets: ws.NewHub()}
+	routersInit, err := doRouter(c, rs, routers, groupsFrom(c))
+	if err != nil {
+		rs.close()
+		return nil, err
 	}
+	readTimeout := utils.If(conf.Web.ReadTimeout <= 0, time.Minute, conf.Web.ReadTimeout*time.Second).(time.Duration)
+	writeTimeout := utils.If(conf.Web.WriteTimeout <= 0, time.Minute, conf.Web.WriteTimeout*time.Second).(time.Duration)
+	endPoint := utils.If(conf.Web.Listen == "", net.JoinHostPort(conf.Web.Host, strconv.Itoa(conf.Web.Port)), conf.Web.Listen).(string)
+	socketMode, err := listener.ParseMode(conf.Web.SocketMode)
+	if err != nil {
+		rs.close()
+		return nil, err
+	}
+	maxHeaderBytes := utils.If(conf.Web.MaxHeaderBytes <= 0, 1<<20, conf.Web.MaxHeaderBytes).(int)
+
+	httpSrv := &http.Server{
+		Addr:              endPoint,
+		Handler:           withRawWriter(routersInit),
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: conf.Web.ReadHeaderTimeout * time.Second,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       conf.Web.IdleTimeout * time.Second,
+		MaxHeaderBytes:    maxHeaderBytes,
+	}
+	// 停机超时后取消所有进行中请求的上下文
+	baseCtx, cancel := context.WithCancel(context.Background())
+	httpSrv.BaseContext = func(net.Listener) context.Context {
+		return baseCtx
+	}
+	httpSrv.ConnContext = connContext
+	httpSrv.SetKeepAlivesEnabled(!conf.Web.DisableKeepAlives)
+	if conf.Web.TLS.Enabled {
+		tlsConfig, err := utils.NewTLSConfig(conf.Web.TLS)
+		if err != nil {
+			cancel()
+			rs.close()
+			return nil, err
+		}
+		httpSrv.TLSConfig = tlsConfig
+	}
+
+	return &Server{
+		httpSrv:      httpSrv,
+		cancel:       cancel,
+		routes:       rs,
+		preStopDelay: conf.Web.PreStopDelay * time.Second,
+		listenOpts: listener.Options{
+			KeepAlive:  conf.Web.TCPKeepAlive * time.Second,
+			SocketMode: socketMode,
+		},
+		done: make(chan struct{}),
+	}, nil
+}
+
+// DrainTimeout 停机排空的总时长，即preStopDelay与shutdownTimeout之和
+func DrainTimeout(conf config.Config) time.Duration {
+	timeout := utils.If(conf.Web.ShutdownTimeout <= 0, 5*time.Second, conf.Web.ShutdownTimeout*time.Second).(time.Duration)
+	return conf.Web.PreStopDelay*time.Second + timeout
+}
+
+// Start 绑定监听地址并在后台提供服务
+func (s *Server) Start() error {
+	ln, err := listener.Listen(s.httpSrv.Addr, s.listenOpts)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
 	go func() {
+		defer close(s.done)
 		// service connections
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+		var err error
+		if s.httpSrv.TLSConfig != nil {
+			err = s.httpSrv.ServeTLS(ln, "", "")
+		} else {
+			err = s.httpSrv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			s.err = err
+			log.Printf("[error] http server: %s", err)
 		}
 	}()
-	log.Printf("[info] start http server listening %s", endPoint)
-	return server
+	log.Printf("[info] start http server listening %s", s.Addr())
+	return nil
+}
+
+// Shutdown 排空并关闭http服务：/health切换为未就绪，等待preStopDelay后关闭WebSocket连接、
+// 停止接收新连接并等待进行中的请求结束，ctx到期后强制关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.routes.health.draining, 1)
+	if s.preStopDelay > 0 {
+		log.Printf("[info] waiting %s for load balancers", s.preStopDelay)
+		select {
+		case <-time.After(s.preStopDelay):
+		case <-ctx.Done():
+		}
+	}
+	defer s.cancel()
+	defer s.routes.close()
+	// 被接管的WebSocket连接不受httpSrv.Shutdown管理，需要单独关闭
+	if err := s.routes.sockets.Shutdown(ctx); err != nil {
+		log.Printf("[warn] websocket shutdown: %s", err)
+	}
+	if err := s.httpSrv.Shutdown(ctx); err != nil {
+		s.cancel()
+		s.httpSrv.Close()
+		return err
+	}
+	return nil
+}
+
+// Addr 实际监听的地址，未启动时返回配置的地址
+func (s *Server) Addr() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.httpSrv.Addr
+}
+
+// Done 服务退出后关闭
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err 服务异常退出的原因，须在Done关闭后读取
+func (s *Server) Err() error {
+	return s.err
 }
 
 func _signal() {
 	// Wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 5 seconds.
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	// kill (no param) default send syscall.SIGTERM
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall.SIGKILL but can't be catch, so don't need add it
@@ -86,42 +336,56 @@ func _signal() {
 	log.Println("Shutdown Server ...")
 }
 
-func _timeout(ctx context.Context, server *http.Server) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func _timeout(c context.Context, server *Server) {
+	conf := ctxkit.Config(c)
+	ctx, cancel := context.WithTimeout(context.Background(), DrainTimeout(conf))
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal("Server Shutdown:", err)
-	}
-	// catching ctx.Done(). timeout of 5 seconds.
-	select {
-	case <-ctx.Done():
-		log.Println("timeout of 5 seconds.")
+		log.Println("Server Shutdown:", err)
 	}
 	log.Println("Server exiting")
 }
 
-func doRouter(c context.Context, routers []Router) *gin.Engine {
-	return router(initGin(c), routers)
+func doRouter(c context.Context, rs *routeState, routers []Router, groups []RouterGroup) (*gin.Engine, error) {
+	r, err := initGin(c)
+	if err != nil {
+		return nil, err
+	}
+	return router(r, rs, routers, groups)
 }
 
-func initGin(c context.Context) (r *gin.Engine) {
+func initGin(c context.Context) (r *gin.Engine, err error) {
 	r = gin.New()
 
-	_config := c.Value(constant.ConfigKey).(config.Config)
+	_config := ctxkit.Config(c)
 
 	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{
 		SkipPaths: _config.Web.LogSkipPath,
 	}))
 	r.Use(gin.Recovery())
+	if _config.Web.Compression.Enabled {
+		compress, err := middleware.Compress(_config.Web.Compression)
+		if err != nil {
+			return nil, err
+		}
+		r.Use(compress)
+	}
 
 	r.Use(middleware.NoCache)
-	r.Use(middleware.Options)
+	cors, err := newCORS(_config.Web.CORS, groupsFrom(c))
+	if err != nil {
+		return nil, err
+	}
+	r.Use(cors)
+	if _config.Web.Security.Enabled {
+		r.Use(middleware.SecurityHeaders(_config.Web.Security))
+	}
 	r.Use(func(ctx *gin.Context) {
-		ctx.Set(constant.ContextKey, c)
+		ctx.Set(constant.ContextKey, requestContext{Context: ctx.Request.Context(), values: c})
 		ctx.Next()
 	})
 	//r.Use(middleware.RequestLogging())
-	mw := c.Value(constant.MiddlewareKey).([]gin.HandlerFunc)
+	mw := middlewareFrom(c)
 	for _, v := range mw {
 		r.Use(v)
 	}
@@ -132,34 +396,71 @@ func initGin(c context.Context) (r *gin.Engine) {
 	r.Use(middleware.Logger())
 	r.Use(ginprom.PromMiddleware(nil))
 	r.Use(middleware.Tracing())
-	return r
+
+	if len(_config.Web.RateLimit.Rules) > 0 {
+		store, err := rateLimitStore(c, _config.Web.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+		limit, err := middleware.RateLimit(_config.Web.RateLimit, store, func(ctx *gin.Context, status int, resp model.Response) {
+			respond(ctx, "", status, resp)
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.Use(limit)
+	}
+
+	if _config.Web.Security.Enabled && _config.Web.Security.CSPReportPath != "" {
+		r.POST(_config.Web.Security.CSPReportPath, cspReport)
+	}
+	return r, nil
 }
 
 /**
 路由
 */
-func router(r *gin.Engine, routers []Router) *gin.Engine {
-	baseHandle(r)
-	doHandle(r, routers)
-	return r
+func router(r *gin.Engine, rs *routeState, routers []Router, groups []RouterGroup) (*gin.Engine, error) {
+	baseHandle(r, rs.health)
+	if err := doHandle(r, rs, routers); err != nil {
+		return nil, err
+	}
+	if err := doGroups(&r.RouterGroup, rs, groups); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+/*
+分组路由处理
+*/
+func doGroups(r *gin.RouterGroup, rs *routeState, groups []RouterGroup) error {
+	for _, group := range groups {
+		g := r.Group(group.Prefix, group.Middleware...)
+		if err := doHandle(g, rs, group.Routers); err != nil {
+			return err
+		}
+		if err := doGroups(g, rs, group.Groups); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 /*
 基础处理
 */
-func baseHandle(r *gin.Engine) {
+func baseHandle(r *gin.Engine, h *health) {
 	// 404 Handler.
 	r.NoRoute(func(c *gin.Context) {
 		resp := model.Response{}
 		resp.SetError(model.ErrorInfo{
 			Code:    http.StatusText(http.StatusNotFound),
 			Message: "The incorrect API route."})
-		c.JSON(http.StatusNotFound, resp)
+		respond(c, "", http.StatusNotFound, resp)
 	})
 
-	r.GET("/health", func(c *gin.Context) {
-		c.String(http.StatusOK, fmt.Sprintf(time.Now().String()))
-	})
+	r.GET("/health", h.handle)
 
 	r.GET("/metrics", ginprom.PromHandler(promhttp.Handler()))
 	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -168,56 +469,136 @@ func baseHandle(r *gin.Engine) {
 /**
 用户函数处理
 */
-func doHandle(r *gin.Engine, routers []Router) {
+func doHandle(r gin.IRoutes, rs *routeState, routers []Router) error {
 	for _, router := range routers {
-		ch := make(chan int)
+		ch := make(chan error)
 		go func(_router Router) {
-			r.Handle(_router.Method, _router.Path, func(ctx *gin.Context) {
+			handlers := append([]gin.HandlerFunc{}, _router.Middleware...)
+			if _router.Mirror != nil && _router.Stream == nil && _router.WebSocket == nil {
+				handlers = append([]gin.HandlerFunc{newMirror(_router)}, handlers...)
+			}
+			if _router.Security != nil {
+				handlers = append([]gin.HandlerFunc{middleware.SecurityHeaders(*_router.Security)}, handlers...)
+			}
+			if _router.Upstream != nil {
+				//负载均衡代理
+				h, err := newProxyHandler(_router, rs)
+				if err != nil {
+					ch <- err
+					return
+				}
+				if _router.Method == "" {
+					r.Any(_router.Path, append(handlers, h)...)
+				} else {
+					r.Handle(_router.Method, _router.Path, append(handlers, h)...)
+				}
+				ch <- nil
+				return
+			}
+			r.Handle(_router.Method, _router.Path, append(handlers, func(ctx *gin.Context) {
 				if _router.ReverseProxy {
 					//透传
-					proxy := &httputil.ReverseProxy{Director: router.Director(ctx.Request)}
+					proxy := &httputil.ReverseProxy{Director: _router.Director(ctx.Request)}
 					proxy.ServeHTTP(ctx.Writer, ctx.Request)
 				} else {
 					//调用
-					addBody(ctx)
+					if !addBody(_router, ctx) {
+						return
+					}
 					addParams(ctx)
 					addHeader(ctx)
 					addRequest(ctx)
-					addShouldBind(ctx)
-					addBind(ctx)
-					call(_router, ctx)
+					addClientSubject(ctx)
+					addBinder(ctx)
+					if _router.InputType != nil && !addInput(_router, ctx) {
+						return
+					}
+					if _router.WebSocket != nil {
+						callWebSocket(_router, rs.sockets, ctx)
+					} else if _router.Stream != nil {
+						callStream(_router, ctx)
+					} else if _router.ResponseCache != nil {
+						callCached(_router, ctx)
+					} else {
+						call(_router, ctx)
+					}
 				}
-			})
-			ch <- 0
+			})...)
+			ch <- nil
 		}(router)
-		<-ch
+		if err := <-ch; err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func addShouldBind(ctx *gin.Context) {
-	setContext(ctx, context.WithValue(getContext(ctx), constant.ShouldBindKey, func(obj interface{}) error {
-		return ctx.ShouldBind(obj)
+/*
+将绑定函数放入上下文。绑定函数使用请求与路径参数的副本而不持有*gin.Context：
+超时后Handler可能仍在运行，而*gin.Context会被gin复用于后续请求
+*/
+func addBinder(ctx *gin.Context) {
+	req := ctx.Request
+	uri := make(map[string][]string, len(ctx.Params))
+	for _, p := range ctx.Params {
+		uri[p.Key] = []string{p.Value}
+	}
+	bind := func(obj interface{}) error {
+		contentType := req.Header.Get("Content-Type")
+		if i := strings.IndexAny(contentType, "; "); i >= 0 {
+			contentType = contentType[:i]
+		}
+		return binding.Default(req.Method, contentType).Bind(req, obj)
+	}
+	bindWith := func(obj interface{}, bt constant.BindingType) error {
+		if bt == constant.BindingUri {
+			return binding.Uri.BindUri(uri, obj)
+		}
+		return getBinding(bt).Bind(req, obj)
+	}
+	setContext(ctx, ctxkit.WithBinder(getContext(ctx), ctxkit.Binder{Bind: bind, BindWith: bindWith}))
+
+	// Deprecated: 旧版本的绑定函数，失败时返回400，弃用期内保留
+	ref := &ginRef{ctx: ctx}
+	c := context.WithValue(getContext(ctx), ginRefKey{}, ref)
+	c = context.WithValue(c, constant.BindKey, func(obj interface{}) error {
+		return ref.abortOnError(bind(obj))
+	})
+	setContext(ctx, context.WithValue(c, constant.BindWithKey, func(obj interface{}, bt constant.BindingType) error {
+		return ref.abortOnError(bindWith(obj, bt))
 	}))
-	setContext(ctx, context.WithValue(getContext(ctx), constant.ShouldBindWithKey,
-		func(obj interface{}, bt constant.BindingType) error {
-			if bt == constant.BindingUri {
-				return ctx.ShouldBindUri(obj)
-			}
-			return ctx.ShouldBindWith(obj, getBinding(bt))
-		}))
 }
 
-func addBind(ctx *gin.Context) {
-	setContext(ctx, context.WithValue(getContext(ctx), constant.BindKey, func(obj interface{}) error {
-		return ctx.Bind(obj)
-	}))
-	setContext(ctx, context.WithValue(getContext(ctx), constant.BindWithKey,
-		func(obj interface{}, bt constant.BindingType) error {
-			if bt == constant.BindingUri {
-				return ctx.BindUri(obj)
-			}
-			return ctx.MustBindWith(obj, getBinding(bt))
-		}))
+// ginRefKey 在上下文中保存ginRef的键
+type ginRefKey struct{}
+
+// ginRef 旧版本绑定函数绑定失败时需要中止请求，超时后解除对*gin.Context的引用
+type ginRef struct {
+	mu  sync.Mutex
+	ctx *gin.Context
+}
+
+func (r *ginRef) abortOnError(err error) error {
+	if err == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ctx != nil {
+		r.ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+	}
+	return err
+}
+
+/*
+解除上下文中绑定函数对*gin.Context的引用，等待进行中的调用结束
+*/
+func detachGin(c context.Context) {
+	if r, ok := c.Value(ginRefKey{}).(*ginRef); ok {
+		r.mu.Lock()
+		r.ctx = nil
+		r.mu.Unlock()
+	}
 }
 
 func getBinding(bt constant.BindingType) binding.Binding {
@@ -251,23 +632,87 @@ func getBinding(bt constant.BindingType) binding.Binding {
 调用用户函数
 */
 func call(_router Router, ctx *gin.Context) {
+	c := getContext(ctx)
+	if _router.Timeout > 0 {
+		var cancel context.CancelFunc
+		c, cancel = context.WithTimeout(c, _router.Timeout)
+		defer cancel()
+	}
 	var tx *gorm.DB
 	if _router.OpenFlatTransaction {
-		tx = db.DB.Begin()
-		setContext(ctx, context.WithValue(getContext(ctx), constant.TransactionKey, tx))
+		// 上下文取消时database/sql会自动回滚事务
+		tx = db.DB.BeginTx(c, nil)
+		c = ctxkit.WithTx(c, tx)
+	}
+	res := newResult()
+	c = context.WithValue(c, resultKey{}, res)
+	setContext(ctx, c)
+
+	resp, hs, ok := invoke(_router, c)
+	if !ok {
+		// 超时后Handler仍可能在使用事务，不能在此并发回滚，由已取消的BeginTx上下文回滚
+		detachGin(c)
+		resp = model.Response{}
+		resp.SetError(model.ErrorInfo{
+			Code:    http.StatusText(http.StatusGatewayTimeout),
+			Message: fmt.Sprintf("The request did not complete within %s.", _router.Timeout)})
+		ctx.Set(constant.ResponseKey, resp)
+		respond(ctx, _router.Produces, http.StatusGatewayTimeout, resp)
+		return
 	}
-	resp, hs := _router.Handler(getContext(ctx))
 	ctx.Set(constant.ResponseKey, resp)
 	if tx != nil && _router.OpenFlatTransaction {
 		if hs >= 400 {
 			tx.Rollback()
-		} else {
-			tx.Commit()
+		} else if err := tx.Commit().Error; err != nil {
+			// 如客户端已断开，上下文取消后事务已被回滚
+			ctxkit.Logger(c).Errorf("commit transaction failed: %s", err)
 		}
 	}
 
-	if unsafe.Sizeof(resp) > 0 {
-		ctx.JSON(int(hs), resp)
+	res.render(ctx, _router, resp, hs)
+}
+
+/*
+执行用户函数，设置了超时时间时在超时后返回ok=false，handler中的panic会转交给gin.Recovery，
+超时后发生的panic只能记录日志
+*/
+func invoke(_router Router, c context.Context) (resp model.Response, hs HttpStatus, ok bool) {
+	if _router.Timeout <= 0 {
+		resp, hs = _router.Handler(c)
+		return resp, hs, true
+	}
+
+	type result struct {
+		resp  model.Response
+		hs    HttpStatus
+		panic interface{}
+		stack []byte
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{panic: p, stack: debug.Stack()}
+			}
+		}()
+		resp, hs := _router.Handler(c)
+		done <- result{resp: resp, hs: hs}
+	}()
+
+	select {
+	case r := <-done:
+		if r.panic != nil {
+			panic(r.panic)
+		}
+		return r.resp, r.hs, true
+	case <-c.Done():
+		go func() {
+			if r := <-done; r.panic != nil {
+				ctxkit.Logger(c).Errorf("handler panic after timeout: %v\n%s", r.panic, r.stack)
+			}
+		}()
+		return resp, hs, false
 	}
 }
 
@@ -275,14 +720,23 @@ func call(_router Router, ctx *gin.Context) {
 将request放入上下文
 */
 func addRequest(ctx *gin.Context) {
-	setContext(ctx, context.WithValue(getContext(ctx), constant.RequestKey, ctx.Request))
+	setContext(ctx, ctxkit.WithRequest(getContext(ctx), ctx.Request))
+}
+
+/*
+将mTLS校验通过的客户端证书主题放入上下文
+*/
+func addClientSubject(ctx *gin.Context) {
+	if subject, ok := utils.ClientSubject(ctx.Request.TLS); ok {
+		setContext(ctx, ctxkit.WithClientSubject(getContext(ctx), subject))
+	}
 }
 
 /*
 将header放入上下文
 */
 func addHeader(ctx *gin.Context) {
-	setContext(ctx, context.WithValue(getContext(ctx), constant.HeaderKey, ctx.Request.Header))
+	setContext(ctx, ctxkit.WithHeader(getContext(ctx), ctx.Request.Header))
 }
 
 /*
@@ -307,17 +761,21 @@ func addParams(ctx *gin.Context) {
 		params[key] = val
 	}
 
-	setContext(ctx, context.WithValue(getContext(ctx), constant.ParamsKey, params))
+	setContext(ctx, ctxkit.WithParams(getContext(ctx), params))
 	return
 }
 
-/*
-将body放入上下文
-*/
-func addBody(ctx *gin.Context) {
-	body, _ := ioutil.ReadAll(ctx.Request.Body)
-	ctx.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-	setContext(ctx, context.WithValue(getContext(ctx), constant.BodyKey, body))
+// requestContext 取消信号与截止时间来自请求，值优先从框架上下文中读取
+type requestContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c requestContext) Value(key interface{}) interface{} {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
 }
 
 func getContext(ctx *gin.Context) context.Context {
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/model"
+	"github.com/mj37yhyy/gowb/pkg/ws"
+)
+
+func init() {
+	gin.SetMode(gin.ReleaseMode)
+}
+
+func newTestEngine(t *testing.T, conf config.Config, routers []Router) *gin.Engine {
+	t.Helper()
+	conf.Web.DisableRequestLogMiddleware = true
+	c := ctxkit.WithConfig(context.Background(), conf)
+	rs := &routeState{health: &health{}, sockets: ws.NewHub()}
+	r, err := doRouter(c, rs, routers, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func serve(r http.Handler, method, target string, body io.Reader, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// 旧版本直接断言字符串键的Handler在弃用期内仍可使用
+func TestLegacyContextKeys(t *testing.T) {
+	r := newTestEngine(t, config.Config{}, []Router{{Path: "/users", Method: "POST", Handler: func(ctx context.Context) (model.Response, HttpStatus) {
+		var in struct{ Name string }
+		if err := ctx.Value(constant.ShouldBindKey).(func(interface{}) error)(&in); err != nil {
+			return model.Response{}, http.StatusBadRequest
+		}
+		body := ctx.Value(constant.BodyKey).([]byte)
+		header := ctx.Value(constant.HeaderKey).(http.Header)
+		return model.Response{Data: in.Name + string(body[:1]) + header.Get("X-A")}, http.StatusOK
+	}}})
+	w := serve(r, "POST", "/users", strings.NewReader(`{"Name":"alice"}`), map[string]string{"Content-Type": "application/json", "X-A": "a"})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"alice{a"`) {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/model"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// Input 返回Router.InputType解码并校验后的实例（指针），未设置InputType时返回nil
+//
+//	in := web.Input(ctx).(*CreateUserInput)
+func Input(ctx context.Context) interface{} {
+	return ctxkit.Input(ctx)
+}
+
+/*
+创建InputType的新实例，返回指针
+*/
+func newInput(inputType interface{}) interface{} {
+	t := reflect.TypeOf(inputType)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return reflect.New(t).Interface()
+}
+
+/*
+将解码或校验错误转换为标准的400响应
+*/
+func validationResponse(err error) model.Response {
+	info := model.ErrorInfo{
+		Code:    http.StatusText(http.StatusBadRequest),
+		Message: "The request parameters are invalid.",
+	}
+	if errs, ok := err.(validator.ValidationErrors); ok {
+		for _, fe := range errs {
+			msg := fmt.Sprintf("%s failed on the '%s' rule", fe.Field(), fe.Tag())
+			if fe.Param() != "" {
+				msg = fmt.Sprintf("%s failed on the '%s=%s' rule", fe.Field(), fe.Tag(), fe.Param())
+			}
+			info.Fields = append(info.Fields, model.FieldError{
+				Field:   fe.Field(),
+				Rule:    fe.Tag(),
+				Message: msg,
+			})
+		}
+	} else {
+		info.Message = err.Error()
+	}
+	resp := model.Response{}
+	resp.SetError(info)
+	return resp
+}
+
+/*
+将path、query、header与body解码到InputType的新实例，校验后放入上下文
+*/
+func addInput(_router Router, ctx *gin.Context) bool {
+	obj := newInput(_router.InputType)
+	err := decodeInput(ctx, obj)
+	if err == nil {
+		err = binding.Validator.ValidateStruct(obj)
+	}
+	if err != nil {
+		resp := validationResponse(err)
+		ctx.Set(constant.ResponseKey, resp)
+		ctx.Abort()
+		respond(ctx, _router.Produces, http.StatusBadRequest, resp)
+		return false
+	}
+	setContext(ctx, ctxkit.WithInput(getContext(ctx), obj))
+	return true
+}
+
+/*
+依次解码各来源，此时只关心解码错误，校验在全部来源解码之后统一进行
+*/
+func decodeInput(ctx *gin.Context, obj interface{}) error {
+	if ctx.Request.URL.RawQuery != "" {
+		if err := ignoreValidation(ctx.ShouldBindQuery(obj)); err != nil {
+			return err
+		}
+	}
+	if err := ignoreValidation(ctx.ShouldBindHeader(obj)); err != nil {
+		return err
+	}
+
+	body := ctxkit.Body(getContext(ctx))
+	if ctx.Request.Method != http.MethodGet && (len(body) > 0 || ctx.Request.MultipartForm != nil) {
+		err := ctx.ShouldBindWith(obj, binding.Default(ctx.Request.Method, ctx.ContentType()))
+		// 还原body，Handler中仍可再次绑定
+		ctx.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+		if err := ignoreValidation(err); err != nil {
+			return err
+		}
+	}
+
+	// path参数优先级最高，最后解码
+	if len(ctx.Params) > 0 {
+		return ignoreValidation(ctx.ShouldBindUri(obj))
+	}
+	return nil
+}
+
+func ignoreValidation(err error) error {
+	if _, ok := err.(validator.ValidationErrors); ok {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,260 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/config"
+)
+
+var defaultCompressTypes = []string{
+	"application/json",
+	"application/xml",
+	"application/x-ndjson",
+	"application/javascript",
+	"image/svg+xml",
+	"text/*",
+}
+
+// encoder gzip.Writer、zlib.Writer与brotli.Writer共有的方法
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressor 解析后的压缩配置
+type compressor struct {
+	minSize int
+	exact   map[string]bool
+	prefix  []string
+	pools   map[string]*sync.Pool
+}
+
+// Compress 按Accept-Encoding压缩响应。响应体达到最小字节数且媒体类型在允许列表中时压缩，
+// 已设置Content-Encoding的响应（如代理的已压缩响应）、SSE、WebSocket升级与HEAD请求不压缩。
+// 压缩后的响应去掉Content-Length，强ETag改为弱ETag
+func Compress(conf config.Compression) (gin.HandlerFunc, error) {
+	level, brLevel := conf.Level, conf.Level
+	if level == 0 {
+		level, brLevel = gzip.DefaultCompression, brotli.DefaultCompression
+	} else if level < gzip.BestSpeed || level > gzip.BestCompression {
+		return nil, fmt.Errorf("compression: invalid level %d", conf.Level)
+	}
+	if len(conf.ContentTypes) == 0 {
+		conf.ContentTypes = defaultCompressTypes
+	}
+	cp := &compressor{
+		minSize: conf.MinSize,
+		exact:   make(map[string]bool),
+		pools: map[string]*sync.Pool{
+			"br": {New: func() interface{} {
+				return brotli.NewWriterLevel(ioutil.Discard, brLevel)
+			}},
+			"gzip": {New: func() interface{} {
+				w, _ := gzip.NewWriterLevel(ioutil.Discard, level)
+				return w
+			}},
+			"deflate": {New: func() interface{} {
+				w, _ := zlib.NewWriterLevel(ioutil.Discard, level)
+				return w
+			}},
+		},
+	}
+	if cp.minSize == 0 {
+		cp.minSize = 1024
+	}
+	for _, t := range conf.ContentTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if strings.HasSuffix(t, "/*") {
+			cp.prefix = append(cp.prefix, strings.TrimSuffix(t, "*"))
+		} else {
+			cp.exact[t] = true
+		}
+	}
+
+	return func(c *gin.Context) {
+		req := c.Request
+		if req.Method == http.MethodHead || req.Header.Get("Upgrade") != "" ||
+			strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+			c.Next()
+			return
+		}
+		encoding := acceptEncoding(req.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, cp: cp, encoding: encoding}
+		c.Writer = w
+		defer func() {
+			w.close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}, nil
+}
+
+func (cp *compressor) compressible(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil || t == "text/event-stream" {
+		return false
+	}
+	if cp.exact[t] {
+		return true
+	}
+	for _, p := range cp.prefix {
+		if strings.HasPrefix(t, p) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+按q值选择br、gzip或deflate，q值相同时依次优先br、gzip，*匹配未列出的编码，都不可用时返回空
+*/
+func acceptEncoding(header string) string {
+	q := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "" {
+			continue
+		}
+		value := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					value = v
+				}
+			}
+		}
+		q[name] = value
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{"br", "gzip", "deflate"} {
+		v, ok := q[encoding]
+		if !ok {
+			v = q["*"]
+		}
+		if v > bestQ {
+			best, bestQ = encoding, v
+		}
+	}
+	return best
+}
+
+// compressWriter 缓存响应体直到可以决定是否压缩
+type compressWriter struct {
+	gin.ResponseWriter
+	cp       *compressor
+	encoding string
+	buf      []byte
+	enc      encoder
+	decided  bool
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if !w.eligible() {
+			w.decide(false)
+		} else {
+			w.buf = append(w.buf, b...)
+			if len(w.buf) >= w.cp.minSize {
+				w.decide(true)
+			}
+			return len(b), nil
+		}
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow 立即发送响应头时不再压缩
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Flush 流式响应的总大小未知，主动Flush时不考虑最小字节数
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(w.eligible())
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) eligible() bool {
+	status := w.Status()
+	h := w.Header()
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusPartialContent &&
+		status != http.StatusNotModified && h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" &&
+		w.cp.compressible(h.Get("Content-Type"))
+}
+
+/*
+决定是否压缩，压缩时改写响应头并写出之前缓存的内容，否则原样写出
+*/
+func (w *compressWriter) decide(compress bool) {
+	w.decided = true
+	buf := w.buf
+	w.buf = nil
+	if !compress {
+		if len(buf) > 0 {
+			w.ResponseWriter.Write(buf)
+		}
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Encoding", w.encoding)
+	h.Add("Vary", "Accept-Encoding")
+	h.Del("Content-Length")
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	w.enc = w.cp.pools[w.encoding].Get().(encoder)
+	w.enc.Reset(w.ResponseWriter)
+	if len(buf) > 0 {
+		w.enc.Write(buf)
+	}
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		// 未达到最小字节数
+		w.decide(false)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(ioutil.Discard)
+		w.cp.pools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
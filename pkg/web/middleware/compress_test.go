@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/config"
+)
+
+func TestAcceptEncoding(t *testing.T) {
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip, deflate, br", "br"},
+		{"gzip, deflate", "gzip"},
+		{"deflate", "deflate"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"gzip;q=0.2, deflate;q=0.8", "deflate"},
+		{"*", "br"},
+		{"*, br;q=0", "gzip"},
+		{"gzip;q=0, deflate;q=0, br;q=0", ""},
+	}
+	for _, c := range cases {
+		if got := acceptEncoding(c.header); got != c.want {
+			t.Errorf("%q: got %q, want %q", c.header, got, c.want)
+		}
+	}
+}
+
+func TestCompressLevel(t *testing.T) {
+	for _, level := range []int{-1, 10} {
+		if _, err := Compress(config.Compression{Level: level}); err == nil {
+			t.Errorf("level %d should fail", level)
+		}
+	}
+}
+
+func decoder(t *testing.T, encoding string, body io.Reader) io.Reader {
+	t.Helper()
+	var r io.Reader
+	var err error
+	switch encoding {
+	case "br":
+		r = brotli.NewReader(body)
+	case "gzip":
+		r, err = gzip.NewReader(body)
+	case "deflate":
+		r, err = zlib.NewReader(body)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestCompress(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	large := strings.Repeat("a", 2048)
+	cases := []struct {
+		name        string
+		method      string
+		header      map[string]string
+		contentType string
+		preset      map[string]string
+		body        string
+		encoding    string
+	}{
+		{"br", "GET", map[string]string{"Accept-Encoding": "gzip, br"}, "application/json", nil, large, "br"},
+		{"gzip", "GET", map[string]string{"Accept-Encoding": "gzip"}, "text/plain; charset=utf-8", nil, large, "gzip"},
+		{"deflate", "GET", map[string]string{"Accept-Encoding": "deflate"}, "image/svg+xml", nil, large, "deflate"},
+		{"not accepted", "GET", nil, "application/json", nil, large, ""},
+		{"below min size", "GET", map[string]string{"Accept-Encoding": "gzip"}, "application/json", nil, "small", ""},
+		{"type not compressible", "GET", map[string]string{"Accept-Encoding": "gzip"}, "image/png", nil, large, ""},
+		{"head", "HEAD", map[string]string{"Accept-Encoding": "gzip"}, "application/json", nil, large, ""},
+		{"sse", "GET", map[string]string{"Accept-Encoding": "gzip", "Accept": "text/event-stream"}, "application/json", nil, large, ""},
+		{"upgrade", "GET", map[string]string{"Accept-Encoding": "gzip", "Upgrade": "websocket"}, "application/json", nil, large, ""},
+		// 代理的已压缩响应原样输出
+		{"already encoded", "GET", map[string]string{"Accept-Encoding": "br"}, "application/json", map[string]string{"Content-Encoding": "identity"}, large, ""},
+		{"partial content", "GET", map[string]string{"Accept-Encoding": "gzip"}, "application/json", map[string]string{"Content-Range": "bytes 0-2047/4096"}, large, ""},
+	}
+	for _, c := range cases {
+		compress, err := Compress(config.Compression{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := gin.New()
+		r.Use(compress)
+		r.Handle(c.method, "/", func(ctx *gin.Context) {
+			for k, v := range c.preset {
+				ctx.Header(k, v)
+			}
+			ctx.Header("ETag", `"v1"`)
+			ctx.Data(http.StatusOK, c.contentType, []byte(c.body))
+		})
+
+		req := httptest.NewRequest(c.method, "/", nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		h := w.Header()
+		if c.encoding == "" {
+			if h.Get("Content-Encoding") != c.preset["Content-Encoding"] || h.Get("ETag") != `"v1"` {
+				t.Errorf("%s: Content-Encoding=%q ETag=%q", c.name, h.Get("Content-Encoding"), h.Get("ETag"))
+			}
+			if c.method != "HEAD" && w.Body.String() != c.body {
+				t.Errorf("%s: body changed", c.name)
+			}
+			continue
+		}
+		if h.Get("Content-Encoding") != c.encoding || h.Get("Vary") != "Accept-Encoding" || h.Get("Content-Length") != "" {
+			t.Errorf("%s: headers %v", c.name, h)
+		}
+		// 压缩后强ETag改为弱ETag
+		if h.Get("ETag") != `W/"v1"` {
+			t.Errorf("%s: ETag %q", c.name, h.Get("ETag"))
+		}
+		if got, err := ioutil.ReadAll(decoder(t, c.encoding, w.Body)); err != nil || string(got) != c.body {
+			t.Errorf("%s: decompressed %d bytes %v, want %d", c.name, len(got), err, len(c.body))
+		}
+	}
+}
+
+// 主动Flush时不考虑最小字节数，已写出的内容可以立即解压
+func TestCompressFlush(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	for _, encoding := range []string{"br", "gzip", "deflate"} {
+		compress, err := Compress(config.Compression{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		flushed := make(chan struct{})
+		finish := make(chan struct{})
+		r := gin.New()
+		r.Use(compress)
+		r.GET("/", func(ctx *gin.Context) {
+			ctx.Header("Content-Type", "application/x-ndjson")
+			ctx.Writer.WriteString("{\"n\":1}\n")
+			ctx.Writer.Flush()
+			close(flushed)
+			<-finish
+			ctx.Writer.WriteString("{\"n\":2}\n")
+		})
+		srv := httptest.NewServer(r)
+
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-flushed
+		if resp.Header.Get("Content-Encoding") != encoding {
+			t.Errorf("%s: Content-Encoding %q", encoding, resp.Header.Get("Content-Encoding"))
+		}
+		dec := decoder(t, encoding, resp.Body)
+		// 第一行在处理函数返回之前即可读到
+		line := make([]byte, len("{\"n\":1}\n"))
+		if _, err := io.ReadFull(dec, line); err != nil || string(line) != "{\"n\":1}\n" {
+			t.Errorf("%s: got %q %v before finish", encoding, line, err)
+		}
+		close(finish)
+		rest, _ := ioutil.ReadAll(dec)
+		if string(rest) != "{\"n\":2}\n" {
+			t.Errorf("%s: rest %q", encoding, rest)
+		}
+		resp.Body.Close()
+		srv.Close()
+	}
+}
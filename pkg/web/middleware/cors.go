@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/config"
+)
+
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+	defaultCORSHeaders = []string{"authorization", "origin", "content-type", "accept"}
+)
+
+// originMatcher 解析后的来源规则
+type originMatcher struct {
+	any    bool
+	exact  map[string]bool
+	wild   [][2]string
+	regexp []*regexp.Regexp
+}
+
+func newOriginMatcher(origins []string) (*originMatcher, error) {
+	m := &originMatcher{exact: make(map[string]bool)}
+	for _, o := range origins {
+		switch {
+		case o == "*":
+			m.any = true
+		case strings.HasPrefix(o, "~"):
+			// 正则须匹配完整的来源，否则https://a\.com也会匹配https://a.com.evil.com
+			re, err := regexp.Compile("^(?:" + o[1:] + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("cors: invalid origin %q: %v", o, err)
+			}
+			m.regexp = append(m.regexp, re)
+		case strings.Contains(o, "*"):
+			i := strings.Index(o, "*")
+			m.wild = append(m.wild, [2]string{strings.ToLower(o[:i]), strings.ToLower(o[i+1:])})
+		default:
+			m.exact[strings.ToLower(o)] = true
+		}
+	}
+	return m, nil
+}
+
+func (m *originMatcher) match(origin string) bool {
+	if m.any {
+		return true
+	}
+	o := strings.ToLower(origin)
+	if m.exact[o] {
+		return true
+	}
+	for _, w := range m.wild {
+		// 通配部分只能是子域名，不能跨越路径或端口
+		if len(o) > len(w[0])+len(w[1]) && strings.HasPrefix(o, w[0]) && strings.HasSuffix(o, w[1]) &&
+			!strings.ContainsAny(o[len(w[0]):len(o)-len(w[1])], "/:") {
+			return true
+		}
+	}
+	for _, re := range m.regexp {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS 按配置处理跨域请求：为允许的来源设置响应头，直接响应预检请求，不带Origin的OPTIONS请求返回Allow
+func CORS(conf config.CORS) (gin.HandlerFunc, error) {
+	if len(conf.AllowOrigins) == 0 {
+		conf.AllowOrigins = []string{"*"}
+	}
+	origins, err := newOriginMatcher(conf.AllowOrigins)
+	if err != nil {
+		return nil, err
+	}
+	if len(conf.AllowMethods) == 0 {
+		conf.AllowMethods = defaultCORSMethods
+	}
+	if len(conf.AllowHeaders) == 0 {
+		conf.AllowHeaders = defaultCORSHeaders
+	}
+	methods := strings.ToUpper(strings.Join(conf.AllowMethods, ","))
+	headers := strings.Join(conf.AllowHeaders, ",")
+	anyHeader := headers == "*"
+	expose := strings.Join(conf.ExposeHeaders, ",")
+	maxAge := ""
+	if conf.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(conf.MaxAge))
+	}
+	// 只有不带凭证的*来源可以直接返回*，其余情况返回请求的Origin，响应随Origin变化
+	wildcard := origins.any && !conf.AllowCredentials
+
+	return func(c *gin.Context) {
+		origin := c.Request.Header.Get("Origin")
+		if origin == "" {
+			if c.Request.Method == http.MethodOptions {
+				c.Header("Allow", methods)
+				c.AbortWithStatus(http.StatusOK)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		if !wildcard {
+			h.Add("Vary", "Origin")
+		}
+		preflight := c.Request.Method == http.MethodOptions && c.Request.Header.Get("Access-Control-Request-Method") != ""
+		if !origins.match(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if wildcard {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if conf.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if expose != "" {
+				h.Set("Access-Control-Expose-Headers", expose)
+			}
+			c.Next()
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", methods)
+		if anyHeader {
+			if requested := c.Request.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				h.Set("Access-Control-Allow-Headers", requested)
+			}
+		} else {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+		if maxAge != "" {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}, nil
+}
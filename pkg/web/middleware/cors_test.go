@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/config"
+)
+
+func TestOriginMatcher(t *testing.T) {
+	cases := []struct {
+		origins []string
+		origin  string
+		want    bool
+	}{
+		{[]string{"*"}, "https://any.com", true},
+		{[]string{"https://a.com"}, "https://a.com", true},
+		{[]string{"https://a.com"}, "HTTPS://A.COM", true},
+		{[]string{"https://a.com"}, "https://b.com", false},
+		{[]string{"https://*.a.com"}, "https://x.a.com", true},
+		{[]string{"https://*.a.com"}, "https://x.y.a.com", true},
+		{[]string{"https://*.a.com"}, "https://a.com", false},
+		{[]string{"https://*.a.com"}, "https://evil.com/.a.com", false},
+		{[]string{"https://*.a.com"}, "https://evil.com:1.a.com", false},
+		{[]string{`~http://localhost:\d+`}, "http://localhost:8080", true},
+		{[]string{`~http://localhost:\d+`}, "http://localhost:8080.evil.com", false},
+		// 正则须匹配完整来源
+		{[]string{`~https://a\.com`}, "https://a.com", true},
+		{[]string{`~https://a\.com`}, "https://a.com.evil.com", false},
+		{[]string{`~https://a\.com`}, "https://evil.com?https://a.com", false},
+		{[]string{`~https://a\.com|https://b\.com`}, "https://b.com", true},
+		{[]string{`~https://a\.com|https://b\.com`}, "https://b.com.evil.com", false},
+	}
+	for _, c := range cases {
+		m, err := newOriginMatcher(c.origins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.match(c.origin); got != c.want {
+			t.Errorf("%v match %q = %v, want %v", c.origins, c.origin, got, c.want)
+		}
+	}
+}
+
+func TestOriginMatcherInvalidRegexp(t *testing.T) {
+	if _, err := newOriginMatcher([]string{"~("}); err == nil {
+		t.Fatal("invalid regexp should fail")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	cases := []struct {
+		name   string
+		conf   config.CORS
+		method string
+		header map[string]string
+		status int
+		want   map[string]string
+	}{
+		{"no origin passes", config.CORS{}, "GET", nil, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""}},
+		{"options without origin", config.CORS{AllowMethods: []string{"get", "post"}}, "OPTIONS", nil, http.StatusOK,
+			map[string]string{"Allow": "GET,POST"}},
+		{"wildcard", config.CORS{}, "GET", map[string]string{"Origin": "https://a.com"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Vary": ""}},
+		// 携带凭证时不能返回*
+		{"wildcard with credentials", config.CORS{AllowCredentials: true}, "GET", map[string]string{"Origin": "https://a.com"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "https://a.com", "Access-Control-Allow-Credentials": "true", "Vary": "Origin"}},
+		{"expose headers", config.CORS{AllowOrigins: []string{"https://a.com"}, ExposeHeaders: []string{"X-Total", "X-Page"}}, "GET",
+			map[string]string{"Origin": "https://a.com"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "https://a.com", "Access-Control-Expose-Headers": "X-Total,X-Page"}},
+		{"origin not allowed", config.CORS{AllowOrigins: []string{"https://a.com"}}, "GET", map[string]string{"Origin": "https://b.com"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"}},
+		{"preflight", config.CORS{AllowOrigins: []string{"https://a.com"}, MaxAge: 600}, "OPTIONS",
+			map[string]string{"Origin": "https://a.com", "Access-Control-Request-Method": "PUT"}, http.StatusNoContent,
+			map[string]string{
+				"Access-Control-Allow-Origin":  "https://a.com",
+				"Access-Control-Allow-Methods": "GET,POST,PUT,PATCH,DELETE,HEAD,OPTIONS",
+				"Access-Control-Allow-Headers": "authorization,origin,content-type,accept",
+				"Access-Control-Max-Age":       "600",
+			}},
+		{"preflight any header", config.CORS{AllowHeaders: []string{"*"}}, "OPTIONS",
+			map[string]string{"Origin": "https://a.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "x-a,x-b"},
+			http.StatusNoContent, map[string]string{"Access-Control-Allow-Headers": "x-a,x-b"}},
+		{"preflight origin not allowed", config.CORS{AllowOrigins: []string{"https://a.com"}}, "OPTIONS",
+			map[string]string{"Origin": "https://b.com", "Access-Control-Request-Method": "PUT"}, http.StatusForbidden,
+			map[string]string{"Access-Control-Allow-Origin": ""}},
+	}
+	for _, c := range cases {
+		cors, err := CORS(c.conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := gin.New()
+		r.Use(cors)
+		r.Any("/", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+		req := httptest.NewRequest(c.method, "/", nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Errorf("%s: got %d, want %d", c.name, w.Code, c.status)
+		}
+		for k, v := range c.want {
+			if got := w.Header().Get(k); got != v {
+				t.Errorf("%s: %s = %q, want %q", c.name, k, got, v)
+			}
+		}
+	}
+}
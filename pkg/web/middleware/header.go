@@ -19,6 +19,8 @@ func NoCache(c *gin.Context) {
 // Options is a middleware function that appends headers
 // for options requests and aborts then exits the middleware
 // chain and ends the request.
+//
+// Deprecated: 使用可配置来源、方法与请求头的CORS
 func Options(c *gin.Context) {
 	if c.Request.Method != "OPTIONS" {
 		c.Next()
@@ -34,6 +36,8 @@ func Options(c *gin.Context) {
 
 // Secure is a middleware function that appends security
 // and resource access headers.
+//
+// Deprecated: 使用可配置的SecurityHeaders
 func Secure(c *gin.Context) {
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("X-Frame-Options", "DENY")
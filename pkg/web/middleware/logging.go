@@ -10,8 +10,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/mj37yhyy/gowb/pkg/config"
 	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
 	"github.com/mj37yhyy/gowb/pkg/model"
 
 	"github.com/gin-gonic/gin"
@@ -96,7 +96,7 @@ func Logger() gin.HandlerFunc {
 		// 获取上下文
 		c := ctx.Value(constant.ContextKey).(context.Context)
 		// 获取配置
-		conf := c.Value(constant.ConfigKey).(config.Config)
+		conf := ctxkit.Config(c)
 
 		// 处理自定义字段
 		fieldMap := make(map[string]interface{})
@@ -116,10 +116,10 @@ func Logger() gin.HandlerFunc {
 		contextLogger := logger.WithFields(fieldMap)
 
 		// 将logger对象插入上下文
-		c = context.WithValue(c, constant.LoggerKey, contextLogger)
+		c = ctxkit.WithLogger(c, contextLogger)
 
 		// audit func
-		auditFunc := func(params AuditLogParams) (*logger.Entry, string) {
+		auditFunc := func(params ctxkit.AuditLogParams) (*logger.Entry, string) {
 
 			auditField := make(map[string]interface{})
 			for key, value := range fieldMap {
@@ -157,7 +157,7 @@ func Logger() gin.HandlerFunc {
 		}
 
 		// 将audit function 对象插入上下文
-		c = context.WithValue(c, constant.AuditLoggerKey, auditFunc)
+		c = ctxkit.WithAudit(c, auditFunc)
 
 		ctx.Set(constant.ContextKey, c)
 		// Continue.
@@ -177,20 +177,10 @@ func getUser(fields map[string]interface{}) (id string, accountType string) {
 	return "", ""
 }
 
-type AuditLogParams struct {
-	Module     string
-	Cluster    string
-	Namespace  string
-	Operate    string
-	ObjectType string
-	Object     string
-	LogLevel   logger.Level
-
-	IsGenerateMsg bool
-}
+// AuditLogParams 兼容旧版本，参见ctxkit.AuditLogParams
+type AuditLogParams = ctxkit.AuditLogParams
 
+// GetAuditLogger 兼容旧版本，参见ctxkit.Audit
 func GetAuditLogger(ctx context.Context, params AuditLogParams) (*logger.Entry, string) {
-	auditFunc := ctx.Value(constant.AuditLoggerKey).(func(params AuditLogParams) (*logger.Entry, string))
-
-	return auditFunc(params)
+	return ctxkit.Audit(ctx, params)
 }
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/model"
+	"github.com/mj37yhyy/gowb/pkg/ratelimit"
+)
+
+// rateLimitRule 解析后的限流规则
+type rateLimitRule struct {
+	name    string
+	method  string
+	path    string
+	prefix  bool
+	keyKind string
+	keyName string
+	policy  ratelimit.Policy
+}
+
+func (r rateLimitRule) match(req *http.Request) bool {
+	if r.method != "" && !strings.EqualFold(r.method, req.Method) {
+		return false
+	}
+	if r.prefix {
+		return strings.HasPrefix(req.URL.Path, r.path)
+	}
+	return r.path == "" || r.path == req.URL.Path
+}
+
+/*
+取计数的键，取不到时返回空字符串
+*/
+func (r rateLimitRule) key(ctx *gin.Context, c context.Context) string {
+	switch r.keyKind {
+	case "header":
+		return ctx.Request.Header.Get(r.keyName)
+	case "field":
+		if v, ok := ctxkit.Logger(c).Data[r.keyName]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	default:
+		return ctx.ClientIP()
+	}
+}
+
+func parseRateLimitRules(conf config.RateLimit) ([]rateLimitRule, error) {
+	rules := make([]rateLimitRule, 0, len(conf.Rules))
+	for _, rc := range conf.Rules {
+		r := rateLimitRule{
+			name:   rc.Name,
+			method: rc.Method,
+			path:   strings.TrimSuffix(rc.Path, "*"),
+			prefix: strings.HasSuffix(rc.Path, "*"),
+			policy: ratelimit.Policy{
+				Algorithm: rc.Algorithm,
+				Limit:     rc.Limit,
+				Window:    rc.Window * time.Second,
+				Burst:     rc.Burst,
+			},
+		}
+		if r.name == "" {
+			r.name = strings.TrimSpace(rc.Method + " " + rc.Path)
+		}
+		r.keyKind = rc.Key
+		if i := strings.Index(rc.Key, ":"); i >= 0 {
+			r.keyKind, r.keyName = rc.Key[:i], rc.Key[i+1:]
+		}
+		switch r.keyKind {
+		case "", "ip":
+		case "header", "field":
+			if r.keyName == "" {
+				return nil, fmt.Errorf("rateLimit rule %q: key %q has no name", r.name, rc.Key)
+			}
+		default:
+			return nil, fmt.Errorf("rateLimit rule %q: unknown key %q", r.name, rc.Key)
+		}
+		if err := r.policy.Validate(); err != nil {
+			return nil, fmt.Errorf("rateLimit rule %q: %v", r.name, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Responder 输出中间件产生的错误响应，用于与Handler的响应使用相同的格式协商
+type Responder func(ctx *gin.Context, status int, resp model.Response)
+
+// RateLimit 按配置的规则限流，超过配额时通过respond返回429，respond为nil时输出JSON。
+// 按日志字段取键时须在Logger之后使用；存储出错时放行请求
+func RateLimit(conf config.RateLimit, store ratelimit.Store, respond Responder) (gin.HandlerFunc, error) {
+	rules, err := parseRateLimitRules(conf)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx *gin.Context) {
+		var rule *rateLimitRule
+		for i := range rules {
+			if rules[i].match(ctx.Request) {
+				rule = &rules[i]
+				break
+			}
+		}
+		if rule == nil {
+			ctx.Next()
+			return
+		}
+		c := ctx.Value(constant.ContextKey).(context.Context)
+		key := rule.key(ctx, c)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+
+		result, err := store.Take(c, rule.name+":"+key, rule.policy)
+		if err != nil {
+			ctxkit.Logger(c).Warnf("rate limit %q: %s", rule.name, err)
+			ctx.Next()
+			return
+		}
+		h := ctx.Writer.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if result.Allowed {
+			ctx.Next()
+			return
+		}
+
+		retryAfter := ceilSeconds(result.RetryAfter)
+		h.Set("Retry-After", strconv.Itoa(retryAfter))
+		resp := model.Response{}
+		resp.SetError(model.ErrorInfo{
+			Code:    http.StatusText(http.StatusTooManyRequests),
+			Message: fmt.Sprintf("Rate limit exceeded, retry after %d seconds.", retryAfter)})
+		ctx.Set(constant.ResponseKey, resp)
+		ctx.Abort()
+		if respond == nil {
+			ctx.JSON(http.StatusTooManyRequests, resp)
+			return
+		}
+		respond(ctx, http.StatusTooManyRequests, resp)
+	}, nil
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/utils"
+)
+
+// 常用的CSP来源
+const (
+	CSPSelf          = "'self'"
+	CSPNone          = "'none'"
+	CSPUnsafeInline  = "'unsafe-inline'"
+	CSPUnsafeEval    = "'unsafe-eval'"
+	CSPStrictDynamic = "'strict-dynamic'"
+)
+
+// CSP Content-Security-Policy构建器，可直接赋值给config.Security.CSP
+//
+//	csp := middleware.NewCSP().
+//		Add("default-src", middleware.CSPSelf).
+//		Add("img-src", middleware.CSPSelf, "data:").
+//		Add("upgrade-insecure-requests")
+type CSP map[string][]string
+
+func NewCSP() CSP {
+	return CSP{}
+}
+
+// Add 为指令添加来源，没有来源的指令（如upgrade-insecure-requests）只传指令名
+func (c CSP) Add(directive string, sources ...string) CSP {
+	c[directive] = append(c[directive], sources...)
+	return c
+}
+
+// String 生成响应头的值，default-src在最前，其余指令按名称排序
+func (c CSP) String() string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "default-src" || names[j] == "default-src" {
+			return names[i] == "default-src"
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, strings.TrimSpace(name+" "+strings.Join(c[name], " ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// securityHeaderNames SecurityHeaders管理的所有响应头
+var securityHeaderNames = []string{
+	"Content-Security-Policy",
+	"Content-Security-Policy-Report-Only",
+	"Strict-Transport-Security",
+	"X-Frame-Options",
+	"X-Content-Type-Options",
+	"Referrer-Policy",
+	"Permissions-Policy",
+	"Cross-Origin-Opener-Policy",
+	"Cross-Origin-Embedder-Policy",
+}
+
+// SecurityHeaders 按配置输出安全响应头，并清除之前设置的同名响应头，
+// 因此可以作为路由中间件覆盖全局配置。Enabled为false时只清除
+func SecurityHeaders(conf config.Security) gin.HandlerFunc {
+	headers := make(map[string]string)
+	hsts := ""
+	if conf.Enabled {
+		headers = securityHeaders(conf)
+		hsts = hstsValue(conf.HSTS)
+	}
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		for _, name := range securityHeaderNames {
+			h.Del(name)
+		}
+		for name, value := range headers {
+			h.Set(name, value)
+		}
+		if hsts != "" && isHTTPS(c.Request) {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
+
+func securityHeaders(conf config.Security) map[string]string {
+	headers := make(map[string]string)
+	set := func(name, value, def string) {
+		if value == "" {
+			value = def
+		}
+		if value != "-" && value != "" {
+			headers[name] = value
+		}
+	}
+	if len(conf.CSP) > 0 {
+		csp := NewCSP()
+		for name, sources := range conf.CSP {
+			csp.Add(name, sources...)
+		}
+		if _, ok := csp["report-uri"]; !ok && conf.CSPReportPath != "" {
+			csp.Add("report-uri", conf.CSPReportPath)
+		}
+		name := "Content-Security-Policy"
+		if conf.CSPReportOnly {
+			name = "Content-Security-Policy-Report-Only"
+		}
+		headers[name] = csp.String()
+	}
+	set("X-Frame-Options", conf.FrameOptions, "DENY")
+	set("X-Content-Type-Options", conf.ContentTypeOptions, "nosniff")
+	set("Referrer-Policy", conf.ReferrerPolicy, "strict-origin-when-cross-origin")
+	set("Permissions-Policy", permissionsPolicy(conf.PermissionsPolicy), "")
+	set("Cross-Origin-Opener-Policy", conf.CrossOriginOpenerPolicy, "")
+	set("Cross-Origin-Embedder-Policy", conf.CrossOriginEmbedderPolicy, "")
+	return headers
+}
+
+func hstsValue(conf config.HSTS) string {
+	if conf.Disabled {
+		return ""
+	}
+	maxAge := utils.If(conf.MaxAge <= 0, 365*24*time.Hour, conf.MaxAge*time.Second).(time.Duration)
+	value := "max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+	if conf.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if conf.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+/*
+生成Permissions-Policy，self与*原样输出，其余来源加引号，如camera=(), geolocation=(self "https://a.com")
+*/
+func permissionsPolicy(features map[string][]string) string {
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		origins := make([]string, 0, len(features[name]))
+		for _, o := range features[name] {
+			if o != "self" && o != "*" && !strings.HasPrefix(o, `"`) {
+				o = strconv.Quote(o)
+			}
+			origins = append(origins, o)
+		}
+		parts = append(parts, name+"=("+strings.Join(origins, " ")+")")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func isHTTPS(req *http.Request) bool {
+	return req.TLS != nil || strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https")
+}
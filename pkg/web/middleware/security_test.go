@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/config"
+)
+
+func TestCSPString(t *testing.T) {
+	csp := NewCSP().
+		Add("img-src", CSPSelf, "data:").
+		Add("upgrade-insecure-requests").
+		Add("default-src", CSPSelf).
+		Add("connect-src", CSPSelf)
+	want := "default-src 'self'; connect-src 'self'; img-src 'self' data:; upgrade-insecure-requests"
+	if got := csp.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestHSTSValue(t *testing.T) {
+	cases := []struct {
+		conf config.HSTS
+		want string
+	}{
+		{config.HSTS{}, "max-age=31536000"},
+		// 配置单位为秒
+		{config.HSTS{MaxAge: 600}, "max-age=600"},
+		{config.HSTS{MaxAge: 600, IncludeSubDomains: true, Preload: true}, "max-age=600; includeSubDomains; preload"},
+		{config.HSTS{Disabled: true, MaxAge: 600}, ""},
+	}
+	for _, c := range cases {
+		if got := hstsValue(c.conf); got != c.want {
+			t.Errorf("%+v: got %q, want %q", c.conf, got, c.want)
+		}
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	cases := []struct {
+		name   string
+		conf   config.Security
+		header map[string]string
+		want   map[string]string
+	}{
+		{"defaults", config.Security{Enabled: true}, nil, map[string]string{
+			"X-Frame-Options":           "DENY",
+			"X-Content-Type-Options":    "nosniff",
+			"Referrer-Policy":           "strict-origin-when-cross-origin",
+			"Content-Security-Policy":   "",
+			"Permissions-Policy":        "",
+			"Strict-Transport-Security": "",
+		}},
+		// -表示不输出
+		{"disable header", config.Security{Enabled: true, FrameOptions: "-", ReferrerPolicy: "no-referrer"}, nil, map[string]string{
+			"X-Frame-Options": "",
+			"Referrer-Policy": "no-referrer",
+		}},
+		{"csp with report path", config.Security{Enabled: true, CSP: map[string][]string{"default-src": {CSPSelf}}, CSPReportPath: "/csp"}, nil,
+			map[string]string{"Content-Security-Policy": "default-src 'self'; report-uri /csp"}},
+		{"csp report only", config.Security{Enabled: true, CSP: map[string][]string{"default-src": {CSPSelf}}, CSPReportOnly: true}, nil,
+			map[string]string{"Content-Security-Policy": "", "Content-Security-Policy-Report-Only": "default-src 'self'"}},
+		{"permissions policy", config.Security{Enabled: true, PermissionsPolicy: map[string][]string{"geolocation": {"self", "https://a.com"}, "camera": {}}}, nil,
+			map[string]string{"Permissions-Policy": `camera=(), geolocation=(self "https://a.com")`}},
+		{"hsts over forwarded https", config.Security{Enabled: true, HSTS: config.HSTS{MaxAge: 600}}, map[string]string{"X-Forwarded-Proto": "https"},
+			map[string]string{"Strict-Transport-Security": "max-age=600"}},
+		// 关闭时清除之前设置的响应头
+		{"disabled clears", config.Security{}, nil, map[string]string{"X-Frame-Options": ""}},
+	}
+	for _, c := range cases {
+		r := gin.New()
+		r.Use(func(ctx *gin.Context) { ctx.Header("X-Frame-Options", "SAMEORIGIN") }, SecurityHeaders(c.conf))
+		r.GET("/", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+		req := httptest.NewRequest("GET", "/", nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		for k, v := range c.want {
+			if got := w.Header().Get(k); got != v {
+				t.Errorf("%s: %s = %q, want %q", c.name, k, got, v)
+			}
+		}
+	}
+}
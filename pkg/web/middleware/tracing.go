@@ -3,8 +3,8 @@ package middleware
 import (
 	"context"
 	"github.com/gin-gonic/gin"
-	"github.com/mj37yhyy/gowb/pkg/config"
 	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
 )
 
 func Tracing() gin.HandlerFunc {
@@ -12,13 +12,13 @@ func Tracing() gin.HandlerFunc {
 		// 获取上下文
 		c := ctx.Value(constant.ContextKey).(context.Context)
 		// 获取配置
-		conf := c.Value(constant.ConfigKey).(config.Config)
+		conf := ctxkit.Config(c)
 		openTracingHeaderNames := conf.Trace.Fields
 		headers := make(map[string]string)
 		for _, headerName := range openTracingHeaderNames {
 			headers[headerName] = ctx.Request.Header.Get(headerName)
 		}
-		c = context.WithValue(c, constant.TraceKey, headers)
+		c = ctxkit.WithTrace(c, headers)
 		ctx.Set(constant.ContextKey, c)
 		// Continue.
 		ctx.Next()
@@ -0,0 +1,308 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// mirrorBodyLimit 镜像的请求体与比较的响应体的最大字节数
+	mirrorBodyLimit = 4 << 20
+	// mirrorConcurrency 每个路由同时进行的镜像请求数，超过时丢弃
+	mirrorConcurrency = 64
+	// mirrorHeader 发往影子上游的请求带有该请求头
+	mirrorHeader = "X-Gowb-Mirror"
+)
+
+// Mirror 流量镜像：按比例把请求异步复制到影子上游或影子Handler并比较结果，不影响返回给客户端的响应。
+// 不支持Stream与WebSocket路由
+type Mirror struct {
+	// 镜像的请求比例，0-100
+	Percent float64
+	// 影子上游地址，如http://new-svc:8080，请求路径与query保持不变（会去掉路由的StripPrefix）
+	Target string
+	// 影子Handler，设置Target时忽略。影子Handler不会开启事务，ctxkit.Tx返回nil
+	Handler HandlerFunc
+	// 影子请求的超时时间，默认5秒
+	Timeout time.Duration
+	// 只比较状态码
+	IgnoreBody bool
+}
+
+var mirrorResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "gowb_mirror_requests_total",
+	Help: "Number of mirrored requests by comparison result.",
+}, []string{"route", "result"})
+
+func init() {
+	prometheus.MustRegister(mirrorResults)
+}
+
+// recordWriter 在输出的同时记录响应体，超过limit字节时不再记录并标记truncated
+type recordWriter struct {
+	gin.ResponseWriter
+	limit     int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *recordWriter) Write(b []byte) (int, error) {
+	w.record(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *recordWriter) record(b []byte) {
+	if w.truncated || w.body.Len()+len(b) > w.limit {
+		w.truncated = true
+		return
+	}
+	w.body.Write(b)
+}
+
+// mirrorResult 一次请求的结果
+type mirrorResult struct {
+	status    int
+	body      []byte
+	truncated bool
+}
+
+type mirror struct {
+	Mirror
+	route  Router
+	client *http.Client
+	sem    chan struct{}
+	mu     sync.Mutex
+	rnd    *rand.Rand
+}
+
+/*
+创建流量镜像中间件，放在路由处理函数之前以记录最终的响应
+*/
+func newMirror(_router Router) gin.HandlerFunc {
+	m := &mirror{
+		Mirror: *_router.Mirror,
+		route:  _router,
+		sem:    make(chan struct{}, mirrorConcurrency),
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if m.Timeout <= 0 {
+		m.Timeout = 5 * time.Second
+	}
+	m.client = &http.Client{
+		Timeout: m.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return m.handle
+}
+
+func (m *mirror) sampled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rnd.Float64()*100 < m.Percent
+}
+
+func (m *mirror) handle(ctx *gin.Context) {
+	if !m.sampled() {
+		ctx.Next()
+		return
+	}
+	body, ok := readMirrorBody(ctx.Request)
+	if !ok {
+		ctx.Next()
+		return
+	}
+	req := ctx.Request.Clone(context.Background())
+	w := &recordWriter{ResponseWriter: ctx.Writer, limit: mirrorBodyLimit}
+	ctx.Writer = w
+	ctx.Next()
+
+	primary := mirrorResult{status: w.Status(), body: w.body.Bytes(), truncated: w.truncated}
+	values := getContext(ctx)
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		mirrorResults.WithLabelValues(m.route.Path, "dropped").Inc()
+		return
+	}
+	go func() {
+		defer func() { <-m.sem }()
+		m.compare(values, req, body, primary)
+	}()
+}
+
+/*
+读取请求体供影子请求使用，并还原主请求的请求体。请求体超过mirrorBodyLimit时不镜像
+*/
+func readMirrorBody(req *http.Request) ([]byte, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true
+	}
+	if req.ContentLength > mirrorBodyLimit {
+		return nil, false
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, mirrorBodyLimit+1))
+	rest := req.Body
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), rest), rest}
+	if err != nil || len(body) > mirrorBodyLimit {
+		return nil, false
+	}
+	return body, true
+}
+
+func (m *mirror) compare(values context.Context, req *http.Request, body []byte, primary mirrorResult) {
+	logger := ctxkit.Logger(values).WithFields(logrus.Fields{
+		"route":          m.route.Path,
+		"primary_status": primary.status,
+	})
+	var (
+		shadow mirrorResult
+		err    error
+	)
+	if m.Target != "" {
+		shadow, err = m.sendTarget(req, body)
+	} else {
+		shadow, err = m.callHandler(values, req)
+	}
+	if err != nil {
+		mirrorResults.WithLabelValues(m.route.Path, "error").Inc()
+		logger.Warnf("mirror request failed: %s", err)
+		return
+	}
+
+	logger = logger.WithField("shadow_status", shadow.status)
+	switch {
+	case shadow.status != primary.status:
+		mirrorResults.WithLabelValues(m.route.Path, "status_mismatch").Inc()
+		logger.Warn("mirror status mismatch")
+	case !m.IgnoreBody && !primary.truncated && !shadow.truncated && !sameBody(primary, shadow):
+		mirrorResults.WithLabelValues(m.route.Path, "body_mismatch").Inc()
+		logger.WithFields(logrus.Fields{
+			"primary_body": string(primary.body),
+			"shadow_body":  string(shadow.body),
+		}).Warn("mirror body mismatch")
+	default:
+		mirrorResults.WithLabelValues(m.route.Path, "match").Inc()
+	}
+}
+
+func (m *mirror) sendTarget(req *http.Request, body []byte) (mirrorResult, error) {
+	path := req.URL.Path
+	if m.route.StripPrefix != "" {
+		path = "/" + strings.TrimPrefix(strings.TrimPrefix(path, m.route.StripPrefix), "/")
+	}
+	url := strings.TrimSuffix(m.Target, "/") + path
+	if req.URL.RawQuery != "" {
+		url += "?" + req.URL.RawQuery
+	}
+	out, err := http.NewRequest(req.Method, url, bytes.NewReader(body))
+	if err != nil {
+		return mirrorResult{}, err
+	}
+	out.Header = req.Header.Clone()
+	out.Header.Set(mirrorHeader, "1")
+	for _, h := range []string{"Connection", "Keep-Alive", "Te", "Trailer", "Transfer-Encoding", "Upgrade"} {
+		out.Header.Del(h)
+	}
+	// 主响应记录的是压缩前的内容，去掉客户端的Accept-Encoding，由Transport透明解压影子响应
+	out.Header.Del("Accept-Encoding")
+
+	resp, err := m.client.Do(out)
+	if err != nil {
+		return mirrorResult{}, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, mirrorBodyLimit+1))
+	if err != nil {
+		return mirrorResult{}, err
+	}
+	return mirrorResult{
+		status:    resp.StatusCode,
+		body:      b,
+		truncated: len(b) > mirrorBodyLimit,
+	}, nil
+}
+
+/*
+在独立的上下文中调用影子Handler，上下文中的请求信息与主请求相同
+*/
+func (m *mirror) callHandler(values context.Context, req *http.Request) (result mirrorResult, err error) {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	defer cancel()
+	res := newResult()
+	c := requestContext{
+		Context: timeoutCtx,
+		values:  context.WithValue(ctxkit.WithTx(values, nil), resultKey{}, res),
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("shadow handler panic: %v", p)
+		}
+	}()
+
+	resp, hs := m.Handler(c)
+	if c.Err() != nil {
+		return result, c.Err()
+	}
+	codec := negotiate(req.Header.Get("Accept"), m.route.Produces)
+	body, err := codec.Marshal(resp)
+	if err != nil {
+		return result, err
+	}
+	if rc, ok := res.reader.(io.Closer); ok {
+		rc.Close()
+	}
+	status := int(hs)
+	switch {
+	case res.kind == resultRedirect:
+		status = redirectStatus(status)
+	case status != 0:
+	case res.kind == resultNoContent:
+		status = http.StatusNoContent
+	default:
+		status = http.StatusOK
+	}
+	if res.kind != resultJSON {
+		// 非model.Response的输出只比较状态码
+		return mirrorResult{status: status, truncated: true}, nil
+	}
+	return mirrorResult{status: status, body: body}, nil
+}
+
+/*
+两个响应都是JSON时忽略字段顺序与空白进行比较
+*/
+func sameBody(a, b mirrorResult) bool {
+	if bytes.Equal(a.body, b.body) {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a.body, &va) == nil && json.Unmarshal(b.body, &vb) == nil {
+		return reflect.DeepEqual(va, vb)
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package web
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 客户端的Accept-Encoding不应转发给影子上游，否则比较的是压缩后的字节
+func TestMirrorTargetEncoding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(mirrorHeader) != "1" {
+			t.Errorf("missing %s", mirrorHeader)
+		}
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Write([]byte(`{"ok":true}`))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(`{"ok":true}`))
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	m := &mirror{Mirror: Mirror{Target: srv.URL}, client: srv.Client()}
+	req := httptest.NewRequest("GET", "/users?id=1", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	shadow, err := m.sendTarget(req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary := mirrorResult{status: http.StatusOK, body: []byte(`{"ok": true}`)}
+	if shadow.status != http.StatusOK || !sameBody(primary, shadow) {
+		t.Fatalf("got %d %q", shadow.status, shadow.body)
+	}
+}
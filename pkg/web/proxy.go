@@ -0,0 +1,80 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/model"
+	"github.com/mj37yhyy/gowb/pkg/proxy"
+)
+
+/*
+配置文件中声明的反向代理路由
+*/
+func proxyRouters(conf config.Config) []Router {
+	var routers []Router
+	for i := range conf.Web.Proxies {
+		p := conf.Web.Proxies[i]
+		routers = append(routers, Router{
+			Path:        p.Path,
+			Method:      p.Method,
+			Upstream:    &p.Upstream,
+			StripPrefix: p.StripPrefix,
+		})
+	}
+	return routers
+}
+
+/*
+创建反向代理路由的处理函数，代理在服务停机时关闭
+*/
+func newProxyHandler(_router Router, rs *routeState) (gin.HandlerFunc, error) {
+	p, err := proxy.New(*_router.Upstream, proxy.Options{
+		Name:        _router.Path,
+		StripPrefix: _router.StripPrefix,
+		Transform:   _router.Transform,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("route %s: %v", _router.Path, err)
+	}
+	p.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		// 客户端已断开，无需响应
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		status, msg := http.StatusBadGateway, "The upstream service is unavailable."
+		var (
+			ne net.Error
+			te *proxy.TransformError
+		)
+		switch {
+		case errors.As(err, &te):
+			ctxkit.Logger(r.Context()).Errorf("proxy %s: %s", _router.Path, err)
+			status, msg = http.StatusInternalServerError, "The proxied request could not be transformed."
+		case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &ne) && ne.Timeout():
+			status = http.StatusGatewayTimeout
+		}
+		resp := model.Response{}
+		resp.SetError(model.ErrorInfo{
+			Code:    http.StatusText(status),
+			Message: msg})
+		writeResponse(w, r, _router.Produces, status, resp)
+	}
+	rs.proxies = append(rs.proxies, p)
+	return func(ctx *gin.Context) {
+		// 带上框架上下文，改写函数与日志可以读取追踪信息
+		p.ServeHTTP(ctx.Writer, ctx.Request.WithContext(getContext(ctx)))
+	}, nil
+}
+
+func (rs *routeState) close() {
+	for _, p := range rs.proxies {
+		p.Close()
+	}
+}
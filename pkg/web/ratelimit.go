@@ -0,0 +1,33 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/db"
+	"github.com/mj37yhyy/gowb/pkg/ratelimit"
+)
+
+// WithRateLimitStore 将自定义的限流存储放入上下文，供NewServer使用，设置后忽略rateLimit.store
+func WithRateLimitStore(c context.Context, store ratelimit.Store) context.Context {
+	return context.WithValue(c, rateLimitStoreKey, store)
+}
+
+func rateLimitStore(c context.Context, conf config.RateLimit) (ratelimit.Store, error) {
+	if store, ok := c.Value(rateLimitStoreKey).(ratelimit.Store); ok && store != nil {
+		return store, nil
+	}
+	switch conf.Store {
+	case "", "memory":
+		return ratelimit.NewMemoryStore(), nil
+	case "redis":
+		if db.Redis == nil {
+			return nil, errors.New("rateLimit store redis requires redis.enabled")
+		}
+		return ratelimit.NewRedisStore(db.Redis, ""), nil
+	default:
+		return nil, fmt.Errorf("unknown rateLimit store %q", conf.Store)
+	}
+}
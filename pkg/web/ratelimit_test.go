@@ -0,0 +1,41 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/model"
+)
+
+// 限流的429与其他错误响应一样按Accept协商格式
+func TestRateLimitResponse(t *testing.T) {
+	conf := config.Config{Web: config.Web{RateLimit: config.RateLimit{
+		Rules: []config.RateLimitRule{{Path: "/users", Limit: 1, Window: 60}},
+	}}}
+	handler := func(ctx context.Context) (model.Response, HttpStatus) {
+		return model.Response{}, http.StatusOK
+	}
+	r := newTestEngine(t, conf, []Router{{Path: "/users", Method: "GET", Handler: handler}})
+
+	cases := []struct {
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"", http.StatusOK, "application/json"},
+		{"application/xml", http.StatusTooManyRequests, "application/xml"},
+		{"", http.StatusTooManyRequests, "application/json"},
+	}
+	for _, c := range cases {
+		w := serve(r, "GET", "/users", nil, map[string]string{"Accept": c.accept})
+		if w.Code != c.status || !strings.HasPrefix(w.Header().Get("Content-Type"), c.contentType) {
+			t.Errorf("Accept %q: got %d %q, want %d %q", c.accept, w.Code, w.Header().Get("Content-Type"), c.status, c.contentType)
+		}
+		if w.Code == http.StatusTooManyRequests && (w.Header().Get("Retry-After") == "" || !strings.Contains(w.Body.String(), "Too Many Requests")) {
+			t.Errorf("Accept %q: got %v %s", c.accept, w.Header(), w.Body)
+		}
+	}
+}
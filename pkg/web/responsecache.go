@@ -0,0 +1,223 @@
+package web
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/cache"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/db"
+)
+
+// responseCacheBodyLimit 可缓存的最大响应体
+const responseCacheBodyLimit = 4 << 20
+
+// ResponseCache 服务端响应缓存，GET、HEAD请求的2xx响应按方法、路径、选定的查询参数与请求头缓存TTL时长，
+// 并发的相同请求只调用一次Handler。数据变化后通过ctxkit.ResponseCache(ctx).Invalidate(ctx, Name)使缓存失效
+//
+//	web.Router{Path: "/accounts/:id/orders", Method: "GET", Handler: ListOrders, ResponseCache: &web.ResponseCache{
+//		TTL: time.Minute, Query: []string{"page", "size"}, Headers: []string{"account_id"}, Name: "orders",
+//	}}
+type ResponseCache struct {
+	// 缓存时长
+	TTL time.Duration
+	// 参与缓存键的查询参数，其余参数不影响命中
+	Query []string
+	// 参与缓存键的请求头
+	Headers []string
+	// 失效时使用的名称，默认为路由的完整路径
+	Name string
+}
+
+// uncachedHeaders 不随缓存的响应保存的响应头：Cookie、逐跳头以及由本次请求的传输与压缩决定的头
+var uncachedHeaders = map[string]bool{
+	"Set-Cookie":          true,
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Content-Length":      true,
+	"Content-Encoding":    true,
+	"X-Cache":             true,
+}
+
+// cachedResponse 缓存的响应，Header为Handler设置的其余响应头
+type cachedResponse struct {
+	Status       int         `json:"status"`
+	ContentType  string      `json:"contentType"`
+	LastModified string      `json:"lastModified,omitempty"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         []byte      `json:"body"`
+}
+
+// WithCacheStore 将自定义的响应缓存存储放入上下文，供NewServer使用，设置后忽略cache.store
+func WithCacheStore(c context.Context, store cache.Store) context.Context {
+	return context.WithValue(c, cacheStoreKey, store)
+}
+
+func cacheStore(c context.Context, conf config.Cache) (cache.Store, error) {
+	if store, ok := c.Value(cacheStoreKey).(cache.Store); ok && store != nil {
+		return store, nil
+	}
+	switch conf.Store {
+	case "", "memory":
+		return cache.NewLRUStore(conf.MaxEntries), nil
+	case "redis":
+		if db.Redis == nil {
+			return nil, errors.New("cache store redis requires redis.enabled")
+		}
+		return cache.NewRedisStore(db.Redis), nil
+	default:
+		return nil, fmt.Errorf("unknown cache store %q", conf.Store)
+	}
+}
+
+/*
+带响应缓存调用用户函数。未命中时由一个请求调用Handler并记录其响应与设置的响应头，等待中的相同请求直接使用该响应；
+响应不可缓存（非2xx、设置了Cookie、超过大小限制）时等待者各自调用Handler，等待期间客户端断开时不再响应
+*/
+func callCached(_router Router, ctx *gin.Context) {
+	c := getContext(ctx)
+	rc := ctxkit.ResponseCache(c)
+	if rc == nil || (ctx.Request.Method != http.MethodGet && ctx.Request.Method != http.MethodHead) {
+		call(_router, ctx)
+		return
+	}
+	name := _router.ResponseCache.Name
+	if name == "" {
+		name = ctx.FullPath()
+	}
+
+	loaded := false
+	value, ok, err := rc.Load(c, name, responseCacheKey(_router, ctx), _router.ResponseCache.TTL, func() ([]byte, bool) {
+		loaded = true
+		ctx.Header("X-Cache", "MISS")
+		// 中间件已设置的响应头（如请求ID、CORS）每个请求各不相同，只保存Handler设置的
+		before := ctx.Writer.Header().Clone()
+		w := &recordWriter{ResponseWriter: ctx.Writer, limit: responseCacheBodyLimit}
+		ctx.Writer = w
+		defer func() { ctx.Writer = w.ResponseWriter }()
+		call(_router, ctx)
+
+		status := w.Status()
+		if status < 200 || status >= 300 || w.truncated || w.Header().Get("Set-Cookie") != "" {
+			return nil, false
+		}
+		b, err := json.Marshal(cachedResponse{
+			Status:       status,
+			ContentType:  w.Header().Get("Content-Type"),
+			LastModified: w.Header().Get("Last-Modified"),
+			Header:       changedHeaders(before, w.Header()),
+			Body:         w.body.Bytes(),
+		})
+		return b, err == nil
+	})
+	if err != nil && c.Err() != nil {
+		return
+	}
+	if err != nil {
+		ctxkit.Logger(c).Errorf("response cache %s: %s", name, err)
+	}
+	if loaded {
+		return
+	}
+	var entry cachedResponse
+	if !ok || json.Unmarshal(value, &entry) != nil {
+		ctx.Header("X-Cache", "MISS")
+		call(_router, ctx)
+		return
+	}
+
+	ctx.Header("X-Cache", "HIT")
+	h := ctx.Writer.Header()
+	for k, v := range entry.Header {
+		if k == "Vary" {
+			addVary(h, v...)
+			continue
+		}
+		h[k] = v
+	}
+	addVary(h, "Accept")
+	if entry.LastModified != "" {
+		h.Set("Last-Modified", entry.LastModified)
+	}
+	if _router.Cache != nil {
+		lastModified, _ := http.ParseTime(entry.LastModified)
+		_router.Cache.apply(h, lastModified)
+		writeConditional(ctx, _router, entry.Status, entry.ContentType, entry.Body, lastModified)
+		return
+	}
+	h.Set("Content-Type", entry.ContentType)
+	ctx.Writer.WriteHeader(entry.Status)
+	ctx.Writer.Write(entry.Body)
+}
+
+/*
+合并Vary，跳过已有的值（不区分大小写，包括逗号分隔的值）
+*/
+func addVary(h http.Header, values ...string) {
+	seen := make(map[string]bool)
+	for _, v := range h["Vary"] {
+		for _, name := range strings.Split(v, ",") {
+			seen[strings.ToLower(strings.TrimSpace(name))] = true
+		}
+	}
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && !seen[strings.ToLower(name)] {
+				seen[strings.ToLower(name)] = true
+				h.Add("Vary", name)
+			}
+		}
+	}
+}
+
+/*
+Handler新增或修改的响应头，不含uncachedHeaders
+*/
+func changedHeaders(before, after http.Header) http.Header {
+	changed := http.Header{}
+	for k, v := range after {
+		if uncachedHeaders[k] || reflect.DeepEqual(before[k], v) {
+			continue
+		}
+		changed[k] = v
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	return changed
+}
+
+/*
+缓存键：方法、路径、选定的查询参数与请求头，以及协商出的响应格式
+*/
+func responseCacheKey(_router Router, ctx *gin.Context) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s\n%s\n", ctx.Request.Method, ctx.Request.URL.Path)
+	query := ctx.Request.URL.Query()
+	for _, name := range _router.ResponseCache.Query {
+		fmt.Fprintf(h, "q:%s=%q\n", name, query[name])
+	}
+	for _, name := range _router.ResponseCache.Headers {
+		fmt.Fprintf(h, "h:%s=%q\n", name, ctx.Request.Header[http.CanonicalHeaderKey(name)])
+	}
+	fmt.Fprintf(h, "%s", negotiate(ctx.Request.Header.Get("Accept"), _router.Produces).ContentType())
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mj37yhyy/gowb/pkg/cache"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/model"
+	"github.com/mj37yhyy/gowb/pkg/ws"
+)
+
+// 命中缓存时仍输出Handler设置的响应头
+func TestResponseCacheHeaders(t *testing.T) {
+	calls := 0
+	handler := func(ctx context.Context) (model.Response, HttpStatus) {
+		calls++
+		Result(ctx).Attachment("report.json").Header().Set("X-Total", "42")
+		return model.Response{Data: calls}, http.StatusOK
+	}
+	c := ctxkit.WithConfig(context.Background(), config.Config{Web: config.Web{DisableRequestLogMiddleware: true}})
+	c = ctxkit.WithResponseCache(c, cache.New(cache.NewLRUStore(0), ""))
+	rs := &routeState{health: &health{}, sockets: ws.NewHub()}
+	r, err := doRouter(c, rs, []Router{{Path: "/report", Method: "GET", Handler: handler,
+		ResponseCache: &ResponseCache{TTL: time.Minute}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"MISS", "HIT"} {
+		w := serve(r, "GET", "/report", nil, nil)
+		h := w.Header()
+		if w.Code != http.StatusOK || h.Get("X-Cache") != want {
+			t.Fatalf("%s: got %d X-Cache=%q", want, w.Code, h.Get("X-Cache"))
+		}
+		if h.Get("X-Total") != "42" || h.Get("Content-Disposition") != `attachment; filename=report.json` {
+			t.Errorf("%s: headers %v", want, h)
+		}
+		if h.Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("%s: Content-Type %q", want, h.Get("Content-Type"))
+		}
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times", calls)
+	}
+}
+
+// 命中缓存时合并Vary，不重复输出
+func TestResponseCacheVary(t *testing.T) {
+	handler := func(ctx context.Context) (model.Response, HttpStatus) {
+		Result(ctx).Header().Set("Vary", "Accept-Language")
+		return model.Response{}, http.StatusOK
+	}
+	c := ctxkit.WithConfig(context.Background(), config.Config{Web: config.Web{DisableRequestLogMiddleware: true}})
+	c = ctxkit.WithResponseCache(c, cache.New(cache.NewLRUStore(0), ""))
+	rs := &routeState{health: &health{}, sockets: ws.NewHub()}
+	r, err := doRouter(c, rs, []Router{{Path: "/report", Method: "GET", Handler: handler,
+		ResponseCache: &ResponseCache{TTL: time.Minute}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Accept-Language", "Accept"}
+	for _, cache := range []string{"MISS", "HIT"} {
+		h := serve(r, "GET", "/report", nil, nil).Header()
+		if h.Get("X-Cache") != cache || !reflect.DeepEqual(h["Vary"], want) {
+			t.Errorf("%s: X-Cache=%q Vary=%q, want %q", cache, h.Get("X-Cache"), h["Vary"], want)
+		}
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{"Vary": {"Origin, accept"}}
+	addVary(h, "Accept", "Accept-Encoding,Origin", "Accept-Encoding")
+	if want := []string{"Origin, accept", "Accept-Encoding"}; !reflect.DeepEqual(h["Vary"], want) {
+		t.Errorf("got %q, want %q", h["Vary"], want)
+	}
+}
+
+func TestChangedHeaders(t *testing.T) {
+	before := http.Header{"X-Request-Id": {"a"}, "Vary": {"Origin"}}
+	after := http.Header{
+		"X-Request-Id":        {"a"},
+		"Vary":                {"Origin", "Accept-Encoding"},
+		"Set-Cookie":          {"s=1"},
+		"Transfer-Encoding":   {"chunked"},
+		"Content-Encoding":    {"gzip"},
+		"Content-Disposition": {"attachment"},
+	}
+	got := changedHeaders(before, after)
+	want := http.Header{"Vary": {"Origin", "Accept-Encoding"}, "Content-Disposition": {"attachment"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/model"
+)
+
+// resultKey 在上下文中保存ResultWriter的键
+type resultKey struct{}
+
+type resultKind int
+
+const (
+	resultJSON resultKind = iota
+	resultNoContent
+	resultRedirect
+	resultReader
+	resultFile
+)
+
+// ResultWriter 控制Handler的响应方式：设置响应头与cookie，或以空响应、重定向、字节流、文件代替JSON。
+// 未调用任何输出方法时仍输出Handler返回的model.Response。Handler返回后不应再修改
+//
+//	web.Result(ctx).Attachment("report.csv").Reader("text/csv", f, -1)
+//	return model.Response{}, http.StatusOK
+type ResultWriter struct {
+	header       http.Header
+	cookies      []*http.Cookie
+	kind         resultKind
+	location     string
+	contentType  string
+	reader       io.Reader
+	size         int64
+	file         string
+	lastModified time.Time
+}
+
+// Result 返回当前请求的ResultWriter，不在Handler中调用时返回一个不会生效的ResultWriter
+func Result(ctx context.Context) *ResultWriter {
+	if r, ok := ctx.Value(resultKey{}).(*ResultWriter); ok {
+		return r
+	}
+	return newResult()
+}
+
+func newResult() *ResultWriter {
+	return &ResultWriter{header: http.Header{}}
+}
+
+// Header 返回需要额外设置的响应头
+func (r *ResultWriter) Header() http.Header {
+	return r.header
+}
+
+// SetCookie 添加Set-Cookie响应头
+func (r *ResultWriter) SetCookie(cookie *http.Cookie) *ResultWriter {
+	r.cookies = append(r.cookies, cookie)
+	return r
+}
+
+// Attachment 设置Content-Disposition，浏览器将以filename下载响应内容
+func (r *ResultWriter) Attachment(filename string) *ResultWriter {
+	r.header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	return r
+}
+
+// LastModified 设置Last-Modified，路由设置了Cache时用于响应If-Modified-Since
+func (r *ResultWriter) LastModified(t time.Time) *ResultWriter {
+	r.lastModified = t
+	return r
+}
+
+// NoContent 不输出响应体，Handler返回的状态码为0时使用204
+func (r *ResultWriter) NoContent() {
+	r.kind = resultNoContent
+}
+
+// Redirect 重定向到location，Handler返回的状态码不是3xx时使用302
+func (r *ResultWriter) Redirect(location string) {
+	r.kind = resultRedirect
+	r.location = location
+}
+
+// Bytes 以指定的Content-Type输出原始字节
+func (r *ResultWriter) Bytes(contentType string, data []byte) {
+	r.Reader(contentType, bytes.NewReader(data), int64(len(data)))
+}
+
+// Reader 以指定的Content-Type输出reader中的内容，size未知时传-1。
+// reader实现了io.Closer时在输出结束后关闭
+func (r *ResultWriter) Reader(contentType string, reader io.Reader, size int64) {
+	r.kind = resultReader
+	r.contentType = contentType
+	r.reader = reader
+	r.size = size
+}
+
+// File 输出本地文件，支持Range与If-Modified-Since，Content-Type按扩展名推断
+func (r *ResultWriter) File(path string) {
+	r.kind = resultFile
+	r.file = path
+}
+
+/*
+按ResultWriter输出响应，未设置输出方式时按协商的格式输出model.Response
+*/
+func (r *ResultWriter) render(ctx *gin.Context, _router Router, resp model.Response, hs HttpStatus) {
+	h := ctx.Writer.Header()
+	status := int(hs)
+	cached := _router.Cache != nil && cacheable(ctx.Request.Method, status)
+	if cached {
+		_router.Cache.apply(h, r.lastModified)
+	}
+	if !r.lastModified.IsZero() {
+		h.Set("Last-Modified", r.lastModified.UTC().Format(http.TimeFormat))
+	}
+	for k, v := range r.header {
+		h[k] = v
+	}
+	for _, cookie := range r.cookies {
+		http.SetCookie(ctx.Writer, cookie)
+	}
+
+	switch r.kind {
+	case resultNoContent:
+		if status == 0 {
+			status = http.StatusNoContent
+		}
+		ctx.Status(status)
+		ctx.Writer.WriteHeaderNow()
+	case resultRedirect:
+		ctx.Redirect(redirectStatus(status), r.location)
+	case resultReader:
+		if c, ok := r.reader.(io.Closer); ok {
+			defer c.Close()
+		}
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if r.contentType != "" {
+			h.Set("Content-Type", r.contentType)
+		}
+		if r.size >= 0 {
+			h.Set("Content-Length", strconv.FormatInt(r.size, 10))
+		}
+		ctx.Status(status)
+		io.Copy(ctx.Writer, r.reader)
+	case resultFile:
+		ctx.File(r.file)
+	default:
+		if cached {
+			respondCached(ctx, _router, status, resp, r.lastModified)
+			return
+		}
+		respond(ctx, _router.Produces, status, resp)
+	}
+}
+
+/*
+gin只接受3xx的重定向状态码，Handler返回其他状态码（如0、200）时使用302
+*/
+func redirectStatus(status int) int {
+	if status >= 300 && status <= 308 {
+		return status
+	}
+	return http.StatusFound
+}
@@ -0,0 +1,35 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/model"
+)
+
+func TestRedirectStatus(t *testing.T) {
+	cases := []struct {
+		returned HttpStatus
+		want     int
+	}{
+		{0, http.StatusFound},
+		{http.StatusOK, http.StatusFound},
+		{http.StatusMovedPermanently, http.StatusMovedPermanently},
+		{http.StatusSeeOther, http.StatusSeeOther},
+		{http.StatusPermanentRedirect, http.StatusPermanentRedirect},
+		{http.StatusBadRequest, http.StatusFound},
+	}
+	for _, c := range cases {
+		hs := c.returned
+		r := newTestEngine(t, config.Config{}, []Router{{Path: "/go", Method: "GET", Handler: func(ctx context.Context) (model.Response, HttpStatus) {
+			Result(ctx).Redirect("/x")
+			return model.Response{}, hs
+		}}})
+		w := serve(r, "GET", "/go", nil, nil)
+		if w.Code != c.want || w.Header().Get("Location") != "/x" {
+			t.Errorf("returned %d: got %d %q, want %d", c.returned, w.Code, w.Header().Get("Location"), c.want)
+		}
+	}
+}
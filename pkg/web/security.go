@@ -0,0 +1,57 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/sirupsen/logrus"
+)
+
+// cspReportLimit CSP违规报告的最大字节数
+const cspReportLimit = 64 << 10
+
+/*
+接收浏览器的CSP违规报告并记录日志，兼容report-uri（application/csp-report）与Reporting API（application/reports+json）两种格式
+*/
+func cspReport(ctx *gin.Context) {
+	body, err := ioutil.ReadAll(io.LimitReader(ctx.Request.Body, cspReportLimit))
+	if err != nil {
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+	var reports []map[string]interface{}
+	var legacy struct {
+		Report map[string]interface{} `json:"csp-report"`
+	}
+	var batch []struct {
+		Type string                 `json:"type"`
+		URL  string                 `json:"url"`
+		Body map[string]interface{} `json:"body"`
+	}
+	switch {
+	case json.Unmarshal(body, &legacy) == nil && legacy.Report != nil:
+		reports = append(reports, legacy.Report)
+	case json.Unmarshal(body, &batch) == nil:
+		for _, r := range batch {
+			if r.Type == "csp-violation" && r.Body != nil {
+				reports = append(reports, r.Body)
+			}
+		}
+	default:
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+
+	logger := ctxkit.Logger(getContext(ctx)).WithFields(logrus.Fields{
+		"from":       ctx.ClientIP(),
+		"user_agent": ctx.Request.UserAgent(),
+	})
+	for _, r := range reports {
+		logger.WithFields(logrus.Fields(r)).Warn("csp violation")
+	}
+	ctx.Status(http.StatusNoContent)
+}
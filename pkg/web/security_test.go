@@ -0,0 +1,65 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/model"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCSPReport(t *testing.T) {
+	buf := &lockedBuffer{}
+	logrus.SetOutput(buf)
+	defer logrus.SetOutput(os.Stderr)
+	conf := config.Config{}
+	conf.Web.Security = config.Security{Enabled: true, CSPReportPath: "/csp-report"}
+	r := newTestEngine(t, conf, nil)
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{"report-uri", `{"csp-report":{"blocked-uri":"https://evil.com/a.js"}}`, http.StatusNoContent, "https://evil.com/a.js"},
+		{"reporting api", `[{"type":"csp-violation","url":"https://a.com","body":{"blockedURL":"https://evil.com/b.js"}},{"type":"deprecation","body":{"id":"x"}}]`,
+			http.StatusNoContent, "https://evil.com/b.js"},
+		{"invalid", `not json`, http.StatusBadRequest, ""},
+	}
+	for _, c := range cases {
+		w := serve(r, "POST", "/csp-report", strings.NewReader(c.body), map[string]string{"Content-Type": "application/csp-report"})
+		if w.Code != c.status {
+			t.Errorf("%s: got %d, want %d", c.name, w.Code, c.status)
+		}
+		if c.want != "" && !strings.Contains(buf.String(), c.want) {
+			t.Errorf("%s: %q not logged in %s", c.name, c.want, buf)
+		}
+	}
+	if strings.Contains(buf.String(), "deprecation") {
+		t.Errorf("non csp report logged: %s", buf)
+	}
+}
+
+// 路由的安全配置覆盖全局配置
+func TestRouterSecurity(t *testing.T) {
+	conf := config.Config{}
+	conf.Web.Security = config.Security{Enabled: true}
+	handler := func(ctx context.Context) (model.Response, HttpStatus) {
+		return model.Response{}, http.StatusOK
+	}
+	r := newTestEngine(t, conf, []Router{
+		{Path: "/global", Method: "GET", Handler: handler},
+		{Path: "/embed", Method: "GET", Handler: handler, Security: &config.Security{Enabled: true, FrameOptions: "SAMEORIGIN"}},
+		{Path: "/off", Method: "GET", Handler: handler, Security: &config.Security{}},
+	})
+	for path, want := range map[string]string{"/global": "DENY", "/embed": "SAMEORIGIN", "/off": ""} {
+		if got := serve(r, "GET", path, nil, nil).Header().Get("X-Frame-Options"); got != want {
+			t.Errorf("%s: X-Frame-Options = %q, want %q", path, got, want)
+		}
+	}
+}
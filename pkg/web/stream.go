@@ -0,0 +1,134 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/db"
+	"github.com/mj37yhyy/gowb/pkg/model"
+	"github.com/mj37yhyy/gowb/pkg/stream"
+)
+
+// StreamFunc 流式处理函数，通过w持续输出，返回后响应结束。
+// 客户端断开后ctx被取消，w的写入返回stream.ErrClosed
+//
+//	func(ctx context.Context, w *stream.Writer) error {
+//		for i := 0; i < 10; i++ {
+//			if err := w.Event("progress", gin.H{"percent": i * 10}); err != nil {
+//				return err
+//			}
+//		}
+//		return nil
+//	}
+type StreamFunc func(ctx context.Context, w *stream.Writer) error
+
+// defaultHeartbeat 流式响应默认的心跳间隔
+const defaultHeartbeat = 15 * time.Second
+
+// connKey 在请求上下文中保存底层连接的键
+type connKey struct{}
+
+func connContext(c context.Context, conn net.Conn) context.Context {
+	return context.WithValue(c, connKey{}, conn)
+}
+
+// rawWriterKey 在请求上下文中保存net/http原始ResponseWriter的键
+type rawWriterKey struct{}
+
+/*
+将net/http原始的ResponseWriter放入请求上下文，gin包装后的ResponseWriter不提供SetWriteDeadline
+*/
+func withRawWriter(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), rawWriterKey{}, w)))
+	})
+}
+
+/*
+调用流式处理函数
+*/
+func callStream(_router Router, ctx *gin.Context) {
+	c := getContext(ctx)
+	if _router.Timeout > 0 {
+		var cancel context.CancelFunc
+		c, cancel = context.WithTimeout(c, _router.Timeout)
+		defer cancel()
+	}
+	var tx *gorm.DB
+	if _router.OpenFlatTransaction {
+		tx = db.DB.BeginTx(c, nil)
+		c = ctxkit.WithTx(c, tx)
+	}
+	setContext(ctx, c)
+	clearWriteDeadline(ctx.Request)
+
+	w := stream.NewWriter(c, ctx.Writer, _router.StreamFormat)
+	heartbeat := _router.Heartbeat
+	if heartbeat == 0 {
+		heartbeat = defaultHeartbeat
+	}
+	stop := w.KeepAlive(heartbeat)
+	err := _router.Stream(c, w)
+	stop()
+
+	if tx != nil {
+		if err != nil || c.Err() != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}
+	if err == nil || err == stream.ErrClosed {
+		return
+	}
+	// 错误只记录日志，返回给客户端的是通用的说明，避免泄露内部信息
+	ctxkit.Logger(c).Errorf("stream %s %s: %s", _router.Method, _router.Path, err)
+	status := http.StatusInternalServerError
+	message := "An internal error occurred while streaming."
+	if c.Err() == context.DeadlineExceeded {
+		status = http.StatusGatewayTimeout
+		message = fmt.Sprintf("The request did not complete within %s.", _router.Timeout)
+	}
+	if w.Started() {
+		// 响应头已发送，只能在流中告知错误
+		switch w.Format() {
+		case stream.SSE:
+			w.Event("error", message)
+		case stream.NDJSON:
+			w.Send(gin.H{"error": message})
+		}
+		return
+	}
+
+	resp := model.Response{}
+	resp.SetError(model.ErrorInfo{
+		Code:    http.StatusText(status),
+		Message: message})
+	ctx.Set(constant.ResponseKey, resp)
+	respond(ctx, _router.Produces, status, resp)
+}
+
+/*
+长连接不受http.Server的WriteTimeout限制。Go 1.20起ResponseWriter（包括HTTP/2的流）提供SetWriteDeadline；
+更早的版本只能清除HTTP/1.x连接的截止时间，HTTP/2的连接由多个流共享，仍受WriteTimeout限制
+*/
+func clearWriteDeadline(req *http.Request) {
+	if w, ok := req.Context().Value(rawWriterKey{}).(interface{ SetWriteDeadline(time.Time) error }); ok {
+		if w.SetWriteDeadline(time.Time{}) == nil {
+			return
+		}
+	}
+	if req.ProtoMajor != 1 {
+		return
+	}
+	if conn, ok := req.Context().Value(connKey{}).(net.Conn); ok {
+		conn.SetWriteDeadline(time.Time{})
+	}
+}
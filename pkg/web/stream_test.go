@@ -0,0 +1,76 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/stream"
+)
+
+// 处理函数的错误不返回给客户端
+func TestStreamErrorHidden(t *testing.T) {
+	secret := errors.New("dial tcp 10.0.0.5:3306: password=hunter2")
+	r := newTestEngine(t, config.Config{}, []Router{
+		{Path: "/before", Method: "GET", Stream: func(ctx context.Context, w *stream.Writer) error {
+			return secret
+		}},
+		{Path: "/after", Method: "GET", Heartbeat: -1, Stream: func(ctx context.Context, w *stream.Writer) error {
+			w.Event("progress", "1")
+			return secret
+		}},
+	})
+	cases := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/before", http.StatusInternalServerError, "An internal error occurred while streaming."},
+		{"/after", http.StatusOK, "event:error\ndata:An internal error occurred while streaming."},
+	}
+	for _, c := range cases {
+		w := serve(r, "GET", c.path, nil, nil)
+		body := w.Body.String()
+		if w.Code != c.status || !strings.Contains(body, c.want) || strings.Contains(body, "hunter2") {
+			t.Errorf("%s: got %d %q", c.path, w.Code, body)
+		}
+	}
+}
+
+// HTTP/2的流式响应也不受WriteTimeout限制
+func TestStreamHTTP2WriteTimeout(t *testing.T) {
+	r := newTestEngine(t, config.Config{}, []Router{{Path: "/events", Method: "GET", Heartbeat: -1,
+		Stream: func(ctx context.Context, w *stream.Writer) error {
+			for i := 0; i < 4; i++ {
+				time.Sleep(50 * time.Millisecond)
+				if err := w.Send(i); err != nil {
+					return err
+				}
+			}
+			return w.Event("done", "ok")
+		}}})
+	srv := httptest.NewUnstartedServer(withRawWriter(r))
+	srv.EnableHTTP2 = true
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.StartTLS()
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("got %s", resp.Proto)
+	}
+	if err != nil || !strings.Contains(string(body), "event:done") {
+		t.Fatalf("stream cut off: %v %q", err, body)
+	}
+}
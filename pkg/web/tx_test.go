@@ -0,0 +1,198 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/db"
+	"github.com/mj37yhyy/gowb/pkg/model"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeDriver 只支持事务与Exec的database/sql驱动，记录回滚次数
+type fakeDriver struct{ rollbacks int32 }
+
+type fakeConn struct{ d *fakeDriver }
+type fakeTx struct{ d *fakeDriver }
+type fakeStmt struct{}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error)  { return &fakeConn{d}, nil }
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return &fakeTx{c.d}, nil }
+func (t *fakeTx) Commit() error                         { return commitErr }
+func (t *fakeTx) Rollback() error                       { atomic.AddInt32(&t.d.rollbacks, 1); return nil }
+func (fakeStmt) Close() error                           { return nil }
+func (fakeStmt) NumInput() int                          { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	time.Sleep(execDelay)
+	return driver.RowsAffected(0), nil
+}
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error) { return nil, errors.New("not supported") }
+func (fakeRows) Columns() []string                         { return nil }
+func (fakeRows) Close() error                              { return nil }
+func (fakeRows) Next([]driver.Value) error                 { return io.EOF }
+
+type fakeRows struct{}
+
+var (
+	fake = &fakeDriver{}
+	// execDelay 模拟不响应上下文取消的慢语句
+	execDelay time.Duration
+	commitErr error
+)
+
+func init() {
+	sql.Register("gowb-fake", fake)
+}
+
+// 超时时Handler正在执行不响应取消的语句：504应立即返回，事务由已取消的上下文回滚一次
+func TestTimeoutTransaction(t *testing.T) {
+	sqlDB, _ := sql.Open("gowb-fake", "")
+	gdb, err := gorm.Open("mysql", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := db.DB
+	db.DB = gdb
+	execDelay = 300 * time.Millisecond
+	atomic.StoreInt32(&fake.rollbacks, 0)
+	defer func() { db.DB, execDelay = old, 0 }()
+
+	finished := make(chan struct{})
+	r := newTestEngine(t, config.Config{}, []Router{{Path: "/slow", Method: "POST", Timeout: 20 * time.Millisecond, OpenFlatTransaction: true,
+		Handler: func(ctx context.Context) (model.Response, HttpStatus) {
+			defer close(finished)
+			ctxkit.Tx(ctx).Exec("UPDATE t SET a = 1")
+			return model.Response{}, http.StatusOK
+		}}})
+	start := time.Now()
+	w := serve(r, "POST", "/slow", nil, nil)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("got %d", w.Code)
+	}
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Fatalf("504 took %s, waited for the running statement", d)
+	}
+	<-finished
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&fake.rollbacks) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&fake.rollbacks); n != 1 {
+		t.Fatalf("rollbacks = %d, want 1", n)
+	}
+}
+
+// 超时后Handler中的绑定不能作用于已被gin复用的*gin.Context
+func TestLateBindAfterTimeout(t *testing.T) {
+	bound := make(chan error, 1)
+	r := newTestEngine(t, config.Config{}, []Router{
+		{Path: "/slow", Method: "POST", Timeout: 20 * time.Millisecond, Handler: func(ctx context.Context) (model.Response, HttpStatus) {
+			time.Sleep(60 * time.Millisecond)
+			var in struct{ A int }
+			bound <- ctx.Value(constant.BindKey).(func(interface{}) error)(&in)
+			return model.Response{}, http.StatusOK
+		}},
+		{Path: "/next", Method: "POST", Handler: func(ctx context.Context) (model.Response, HttpStatus) {
+			time.Sleep(100 * time.Millisecond)
+			return model.Response{Data: string(ctxkit.Body(ctx))}, http.StatusOK
+		}},
+	})
+	json := map[string]string{"Content-Type": "application/json"}
+	if w := serve(r, "POST", "/slow", strings.NewReader("not json"), json); w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("slow: got %d", w.Code)
+	}
+	w := serve(r, "POST", "/next", strings.NewReader(`{"A":1}`), json)
+	if w.Code != http.StatusOK {
+		t.Fatalf("next: got %d %s", w.Code, w.Body)
+	}
+	if err := <-bound; err == nil {
+		t.Fatal("late bind of the slow request's body should fail")
+	}
+}
+
+// lockedBuffer 可以在写日志的同时读取
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// 超时后发生的panic记录日志
+func TestLatePanicLogged(t *testing.T) {
+	buf := &lockedBuffer{}
+	logrus.SetOutput(buf)
+	defer logrus.SetOutput(os.Stderr)
+	panicked := make(chan struct{})
+	r := newTestEngine(t, config.Config{}, []Router{{Path: "/slow", Method: "GET", Timeout: 10 * time.Millisecond,
+		Handler: func(ctx context.Context) (model.Response, HttpStatus) {
+			defer close(panicked)
+			time.Sleep(30 * time.Millisecond)
+			panic("late boom")
+		}}})
+	if w := serve(r, "GET", "/slow", nil, nil); w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("got %d", w.Code)
+	}
+	<-panicked
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(buf.String(), "late boom") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !strings.Contains(buf.String(), "handler panic after timeout: late boom") {
+		t.Fatalf("log: %s", buf.String())
+	}
+}
+
+// 提交失败时记录日志
+func TestCommitErrorLogged(t *testing.T) {
+	sqlDB, _ := sql.Open("gowb-fake", "")
+	gdb, err := gorm.Open("mysql", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := db.DB
+	db.DB = gdb
+	commitErr = errors.New("commit lost")
+	buf := &lockedBuffer{}
+	logrus.SetOutput(buf)
+	defer func() {
+		db.DB, commitErr = old, nil
+		logrus.SetOutput(os.Stderr)
+	}()
+
+	r := newTestEngine(t, config.Config{}, []Router{{Path: "/users", Method: "POST", OpenFlatTransaction: true,
+		Handler: func(ctx context.Context) (model.Response, HttpStatus) {
+			return model.Response{}, http.StatusOK
+		}}})
+	serve(r, "POST", "/users", nil, nil)
+	if !strings.Contains(buf.String(), "commit transaction failed: commit lost") {
+		t.Fatalf("log: %s", buf.String())
+	}
+}
@@ -0,0 +1,222 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/mj37yhyy/gowb/pkg/constant"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/model"
+)
+
+const (
+	// defaultMultipartMemory multipart上传默认在内存中保留的字节数
+	defaultMultipartMemory = 32 << 20
+	// defaultDecodedBodyLimit 未限制请求体大小时，解压后请求体的最大字节数，防止压缩炸弹
+	defaultDecodedBodyLimit = 32 << 20
+)
+
+// filesKey 在上下文中保存上传文件的键
+type filesKey struct{}
+
+// UploadedFile multipart上传的文件，超过multipartMemory的文件已写入临时文件，请求结束后自动删除
+type UploadedFile struct {
+	Field       string
+	Filename    string
+	Size        int64
+	ContentType string
+	header      *multipart.FileHeader
+}
+
+// Open 以流的方式读取文件内容，使用后须关闭
+func (f UploadedFile) Open() (multipart.File, error) {
+	return f.header.Open()
+}
+
+// Files 返回multipart请求上传的所有文件，非multipart请求返回nil
+//
+//	for _, f := range web.Files(ctx) {
+//		r, err := f.Open()
+//		...
+//	}
+func Files(ctx context.Context) []UploadedFile {
+	files, _ := ctx.Value(filesKey{}).([]UploadedFile)
+	return files
+}
+
+/*
+将body放入上下文，超过最大字节数时返回413。multipart请求不读入内存，解析后将文件放入上下文，无法解析时返回400。
+gzip、deflate、br编码的请求体先解压，最大字节数作用于解压后的内容，未限制时解压后最多defaultDecodedBodyLimit字节
+*/
+func addBody(_router Router, ctx *gin.Context) bool {
+	conf := ctxkit.Config(getContext(ctx))
+	limit := conf.Web.MaxBodySize
+	if _router.MaxBodySize != 0 {
+		limit = _router.MaxBodySize
+	}
+	decoded, ok := decodeBody(_router, ctx)
+	if !ok {
+		return false
+	}
+	if decoded && limit <= 0 {
+		limit = defaultDecodedBodyLimit
+	}
+	body := &limitedBody{ReadCloser: ctx.Request.Body, n: limit}
+	if limit > 0 {
+		if !decoded && ctx.Request.ContentLength > limit {
+			entityTooLarge(_router, ctx, limit)
+			return false
+		}
+		ctx.Request.Body = body
+	}
+
+	if strings.HasPrefix(ctx.ContentType(), gin.MIMEMultipartPOSTForm) {
+		memory := conf.Web.MultipartMemory
+		if memory <= 0 {
+			memory = defaultMultipartMemory
+		}
+		err := ctx.Request.ParseMultipartForm(memory)
+		if body.exceeded {
+			entityTooLarge(_router, ctx, limit)
+			return false
+		}
+		if err != nil {
+			rejectBody(_router, ctx, http.StatusBadRequest, fmt.Sprintf("The multipart body cannot be parsed: %s.", err))
+			return false
+		}
+		setContext(ctx, context.WithValue(getContext(ctx), filesKey{}, uploadedFiles(ctx.Request.MultipartForm)))
+		return true
+	}
+
+	data, err := ioutil.ReadAll(ctx.Request.Body)
+	if body.exceeded {
+		entityTooLarge(_router, ctx, limit)
+		return false
+	}
+	if err != nil && decoded {
+		rejectBody(_router, ctx, http.StatusBadRequest, fmt.Sprintf("The request body cannot be decoded: %s.", err))
+		return false
+	}
+	ctx.Request.Body = ioutil.NopCloser(bytes.NewBuffer(data))
+	setContext(ctx, ctxkit.WithBody(getContext(ctx), data))
+	return true
+}
+
+func uploadedFiles(form *multipart.Form) []UploadedFile {
+	fields := make([]string, 0, len(form.File))
+	for field := range form.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var files []UploadedFile
+	for _, field := range fields {
+		for _, fh := range form.File[field] {
+			files = append(files, UploadedFile{
+				Field:       field,
+				Filename:    fh.Filename,
+				Size:        fh.Size,
+				ContentType: fh.Header.Get("Content-Type"),
+				header:      fh,
+			})
+		}
+	}
+	return files
+}
+
+// errBodyTooLarge 请求体超过最大字节数
+var errBodyTooLarge = errors.New("http: request body too large")
+
+// limitedBody 读取超过n字节时返回errBodyTooLarge并记录exceeded。
+// multipart解析可能以%v包装读取错误，因此以exceeded而不是错误类型判断是否超限
+type limitedBody struct {
+	io.ReadCloser
+	n        int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errBodyTooLarge
+	}
+	// 多读一个字节以区分恰好等于n与超过n
+	if int64(len(p)) > b.n+1 {
+		p = p[:b.n+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.n {
+		b.n -= int64(n)
+		return n, err
+	}
+	n, b.n = int(b.n), 0
+	b.exceeded = true
+	return n, errBodyTooLarge
+}
+
+/*
+按Content-Encoding解压请求体，decoded表示请求体已被替换为解压后的内容
+*/
+func decodeBody(_router Router, ctx *gin.Context) (decoded bool, ok bool) {
+	encoding := strings.ToLower(strings.TrimSpace(ctx.GetHeader("Content-Encoding")))
+	var r io.Reader
+	var err error
+	switch encoding {
+	case "", "identity":
+		return false, true
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(ctx.Request.Body)
+	case "deflate":
+		r, err = zlib.NewReader(ctx.Request.Body)
+	case "br":
+		r = brotli.NewReader(ctx.Request.Body)
+	default:
+		rejectBody(_router, ctx, http.StatusUnsupportedMediaType, fmt.Sprintf("The content encoding %q is not supported.", encoding))
+		return false, false
+	}
+	if err != nil {
+		rejectBody(_router, ctx, http.StatusBadRequest, fmt.Sprintf("The request body cannot be decoded: %s.", err))
+		return false, false
+	}
+	ctx.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{r, ctx.Request.Body}
+	ctx.Request.Header.Del("Content-Encoding")
+	ctx.Request.Header.Del("Content-Length")
+	ctx.Request.ContentLength = -1
+	return true, true
+}
+
+func rejectBody(_router Router, ctx *gin.Context, status int, message string) {
+	resp := model.Response{}
+	resp.SetError(model.ErrorInfo{
+		Code:    http.StatusText(status),
+		Message: message})
+	ctx.Set(constant.ResponseKey, resp)
+	ctx.Abort()
+	respond(ctx, _router.Produces, status, resp)
+}
+
+func entityTooLarge(_router Router, ctx *gin.Context, limit int64) {
+	// 未读完的请求体留在连接上，响应后关闭连接
+	ctx.Header("Connection", "close")
+	resp := model.Response{}
+	resp.SetError(model.ErrorInfo{
+		Code:    http.StatusText(http.StatusRequestEntityTooLarge),
+		Message: fmt.Sprintf("The request body exceeds the limit of %d bytes.", limit)})
+	ctx.Set(constant.ResponseKey, resp)
+	ctx.Abort()
+	respond(ctx, _router.Produces, http.StatusRequestEntityTooLarge, resp)
+}
@@ -0,0 +1,174 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/model"
+)
+
+func TestLimitedBody(t *testing.T) {
+	cases := []struct {
+		size, limit int
+		exceeded    bool
+	}{
+		{0, 10, false},
+		{9, 10, false},
+		{10, 10, false},
+		{11, 10, true},
+		{1 << 16, 10, true},
+	}
+	for _, c := range cases {
+		b := &limitedBody{ReadCloser: ioutil.NopCloser(strings.NewReader(strings.Repeat("x", c.size))), n: int64(c.limit)}
+		data, err := ioutil.ReadAll(b)
+		if b.exceeded != c.exceeded || (err != nil) != c.exceeded {
+			t.Errorf("size %d limit %d: exceeded=%v err=%v", c.size, c.limit, b.exceeded, err)
+		}
+		if !c.exceeded && len(data) != c.size {
+			t.Errorf("size %d limit %d: read %d bytes", c.size, c.limit, len(data))
+		}
+		if len(data) > c.limit {
+			t.Errorf("size %d limit %d: read past limit (%d)", c.size, c.limit, len(data))
+		}
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	conf := config.Config{Web: config.Web{MaxBodySize: 100}}
+	handler := func(ctx context.Context) (model.Response, HttpStatus) {
+		return model.Response{Data: len(ctxkit.Body(ctx)) + len(Files(ctx))}, http.StatusOK
+	}
+	r := newTestEngine(t, conf, []Router{{Path: "/", Method: "POST", Handler: handler}})
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, _ := mw.CreateFormFile("f", "a.txt")
+	fw.Write(bytes.Repeat([]byte("y"), 500))
+	mw.Close()
+
+	cases := []struct {
+		name        string
+		body        string
+		contentType string
+		chunked     bool
+		want        int
+	}{
+		{"small", `{"a":1}`, "application/json", false, http.StatusOK},
+		{"content-length", strings.Repeat("x", 101), "application/json", false, http.StatusRequestEntityTooLarge},
+		{"chunked", strings.Repeat("x", 101), "application/json", true, http.StatusRequestEntityTooLarge},
+		{"multipart", form.String(), mw.FormDataContentType(), true, http.StatusRequestEntityTooLarge},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		if c.chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, w.Code, c.want)
+		}
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	handler := func(ctx context.Context) (model.Response, HttpStatus) {
+		return model.Response{Data: string(ctxkit.Body(ctx))}, http.StatusOK
+	}
+	r := newTestEngine(t, config.Config{}, []Router{{Path: "/", Method: "POST", Handler: handler}})
+
+	compress := func(encoding string, data []byte) string {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "br":
+			w = brotli.NewWriter(&buf)
+		default:
+			w = gzip.NewWriter(&buf)
+		}
+		w.Write(data)
+		w.Close()
+		return buf.String()
+	}
+	// 未设置maxBodySize时解压后的内容也不能超过defaultDecodedBodyLimit
+	bomb := compress("gzip", make([]byte, defaultDecodedBodyLimit+1))
+
+	cases := []struct {
+		name     string
+		encoding string
+		body     string
+		want     int
+	}{
+		{"identity", "", `{"a":1}`, http.StatusOK},
+		{"gzip", "gzip", compress("gzip", []byte(`{"a":1}`)), http.StatusOK},
+		{"x-gzip", "x-gzip", compress("gzip", []byte(`{"a":1}`)), http.StatusOK},
+		{"deflate", "deflate", compress("deflate", []byte(`{"a":1}`)), http.StatusOK},
+		{"br", "br", compress("br", []byte(`{"a":1}`)), http.StatusOK},
+		{"br bomb", "br", compress("br", make([]byte, defaultDecodedBodyLimit+1)), http.StatusRequestEntityTooLarge},
+		{"bad br", "br", "not br", http.StatusBadRequest},
+		{"unsupported", "zstd", "x", http.StatusUnsupportedMediaType},
+		{"bad header", "gzip", "not gzip", http.StatusBadRequest},
+		{"truncated", "gzip", compress("gzip", []byte(`{"a":1}`))[:15], http.StatusBadRequest},
+		{"bomb", "gzip", bomb, http.StatusRequestEntityTooLarge},
+	}
+	for _, c := range cases {
+		header := map[string]string{"Content-Type": "application/json"}
+		if c.encoding != "" {
+			header["Content-Encoding"] = c.encoding
+		}
+		w := serve(r, "POST", "/", strings.NewReader(c.body), header)
+		if w.Code != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, w.Code, c.want)
+		}
+		if w.Code == http.StatusOK && !strings.Contains(w.Body.String(), `{\"a\":1}`) {
+			t.Errorf("%s: got body %s", c.name, w.Body)
+		}
+	}
+}
+
+// multipart无法解析时返回400，而不是在没有文件的情况下调用Handler
+func TestMultipartParseError(t *testing.T) {
+	handler := func(ctx context.Context) (model.Response, HttpStatus) {
+		return model.Response{Data: len(Files(ctx))}, http.StatusOK
+	}
+	r := newTestEngine(t, config.Config{}, []Router{{Path: "/", Method: "POST", Handler: handler}})
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, _ := mw.CreateFormFile("f", "a.txt")
+	fw.Write([]byte("hello"))
+	mw.Close()
+
+	cases := []struct {
+		name        string
+		body        string
+		contentType string
+		want        int
+	}{
+		{"valid", form.String(), mw.FormDataContentType(), http.StatusOK},
+		{"no boundary", form.String(), "multipart/form-data", http.StatusBadRequest},
+		{"wrong boundary", form.String(), "multipart/form-data; boundary=other", http.StatusBadRequest},
+		{"truncated", form.String()[:form.Len()-10], mw.FormDataContentType(), http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := serve(r, "POST", "/", strings.NewReader(c.body), map[string]string{"Content-Type": c.contentType})
+		if w.Code != c.want {
+			t.Errorf("%s: got %d %s, want %d", c.name, w.Code, w.Body, c.want)
+		}
+	}
+}
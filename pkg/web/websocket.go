@@ -0,0 +1,64 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/ws"
+)
+
+// WebSocketFunc WebSocket处理函数，返回后连接关闭。ctx带有与普通Handler相同的日志、追踪与审计信息，
+// 连接断开或服务停机时被取消
+//
+//	func(ctx context.Context, conn *ws.Conn) error {
+//		for {
+//			var msg Subscribe
+//			if err := conn.ReadJSON(&msg); err != nil {
+//				return err
+//			}
+//			if err := conn.WriteJSON(Ack{Topic: msg.Topic}); err != nil {
+//				return err
+//			}
+//		}
+//	}
+type WebSocketFunc func(ctx context.Context, conn *ws.Conn) error
+
+/*
+完成握手并调用WebSocket处理函数
+*/
+func callWebSocket(_router Router, hub *ws.Hub, ctx *gin.Context) {
+	c := getContext(ctx)
+	heartbeat := _router.Heartbeat
+	if heartbeat == 0 {
+		heartbeat = defaultHeartbeat
+	}
+	conn, err := ws.Upgrade(c, ctx.Writer, ctx.Request, ws.Options{PingInterval: heartbeat})
+	if err != nil {
+		// 握手失败时upgrader已经返回了错误响应
+		ctxkit.Logger(c).Warnf("websocket %s: %s", _router.Path, err)
+		return
+	}
+	if err := hub.Add(conn); err != nil {
+		conn.CloseWith(websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+	defer hub.Remove(conn)
+
+	c = conn.Context()
+	setContext(ctx, c)
+	err = _router.WebSocket(c, conn)
+	if err != nil && c.Err() == nil && !isCloseError(err) {
+		ctxkit.Logger(c).Errorf("websocket %s: %s", _router.Path, err)
+		conn.CloseWith(websocket.CloseInternalServerErr, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	conn.Close()
+}
+
+func isCloseError(err error) bool {
+	_, ok := err.(*websocket.CloseError)
+	return ok
+}
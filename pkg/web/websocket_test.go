@@ -0,0 +1,80 @@
+package web
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mj37yhyy/gowb/pkg/config"
+	"github.com/mj37yhyy/gowb/pkg/ctxkit"
+	"github.com/mj37yhyy/gowb/pkg/ws"
+)
+
+// 停机时以1001关闭WebSocket连接并等待处理函数返回，之后的新连接立即被关闭
+func TestWebSocketShutdown(t *testing.T) {
+	returned := make(chan error, 1)
+	echo := func(ctx context.Context, conn *ws.Conn) error {
+		defer func() { returned <- ctx.Err() }()
+		for {
+			var msg map[string]string
+			if err := conn.ReadJSON(&msg); err != nil {
+				return err
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				return err
+			}
+		}
+	}
+	c := ctxkit.WithConfig(context.Background(), config.Config{Web: config.Web{DisableRequestLogMiddleware: true}})
+	rs := &routeState{health: &health{}, sockets: ws.NewHub()}
+	r, err := doRouter(c, rs, []Router{{Path: "/ws", Method: "GET", WebSocket: echo}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var msg map[string]string
+	if err := conn.WriteJSON(map[string]string{"a": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.ReadJSON(&msg); err != nil || msg["a"] != "1" {
+		t.Fatalf("echo got %v %v", msg, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := rs.sockets.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	// Shutdown返回时处理函数已经返回，且其上下文已被取消
+	select {
+	case err := <-returned:
+		if err == nil {
+			t.Error("handler context not canceled")
+		}
+	default:
+		t.Error("handler still running after shutdown")
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("client got %v, want close 1001", err)
+	}
+
+	late, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.Close()
+	if _, _, err := late.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("late client got %v, want close 1001", err)
+	}
+}
@@ -0,0 +1,217 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// writeWait 单次写入的超时时间
+const writeWait = 10 * time.Second
+
+// ErrShuttingDown 服务停机中，不再接受新连接
+var ErrShuttingDown = errors.New("ws: server is shutting down")
+
+// Options 握手与保活选项
+type Options struct {
+	// ping间隔，在2倍间隔内未收到任何消息（含pong）视为连接已断开，0或负数关闭保活
+	PingInterval time.Duration
+	// 单条消息的最大字节数，0不限制
+	ReadLimit int64
+	// 校验Origin，nil时只允许同源请求
+	CheckOrigin func(r *http.Request) bool
+}
+
+// Conn WebSocket连接，写入方法可并发调用，读取方法只能在一个goroutine中调用。
+// 保活依赖读取：处理函数须持续调用ReadJSON或ReadMessage，pong与close帧才会被处理
+type Conn struct {
+	conn      *websocket.Conn
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wmu       sync.Mutex
+	closeOnce sync.Once
+}
+
+// Upgrade 完成WebSocket握手，握手失败时已向客户端写入错误响应。
+// 连接关闭、读取失败或ctx取消时返回的Conn上下文被取消
+func Upgrade(ctx context.Context, w http.ResponseWriter, r *http.Request, opts Options) (*Conn, error) {
+	upgrader := websocket.Upgrader{CheckOrigin: opts.CheckOrigin}
+	wc, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ReadLimit > 0 {
+		wc.SetReadLimit(opts.ReadLimit)
+	}
+
+	c := &Conn{conn: wc}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	if opts.PingInterval > 0 {
+		pongWait := 2 * opts.PingInterval
+		wc.SetReadDeadline(time.Now().Add(pongWait))
+		wc.SetPongHandler(func(string) error {
+			return wc.SetReadDeadline(time.Now().Add(pongWait))
+		})
+	}
+	go c.keepAlive(opts.PingInterval)
+	return c, nil
+}
+
+// Context 返回连接的上下文，连接关闭后被取消
+func (c *Conn) Context() context.Context {
+	return c.ctx
+}
+
+// Done 连接关闭后关闭
+func (c *Conn) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+// ReadJSON 读取一条消息并按JSON解码到v。读取失败后连接关闭，解码失败时连接仍可继续使用
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, p, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(p, v)
+}
+
+// ReadMessage 读取一条消息，返回消息类型（websocket.TextMessage或websocket.BinaryMessage）与内容
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	mt, p, err := c.conn.ReadMessage()
+	if err != nil {
+		c.close()
+	}
+	return mt, p, err
+}
+
+// WriteJSON 将v编码为JSON并作为文本消息发送
+func (c *Conn) WriteJSON(v interface{}) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteJSON(v)
+}
+
+// WriteMessage 发送一条指定类型的消息
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// Close 以正常关闭（1000）结束连接
+func (c *Conn) Close() error {
+	return c.CloseWith(websocket.CloseNormalClosure, "")
+}
+
+// CloseWith 发送close帧后关闭连接，code取值见websocket.CloseXXX
+func (c *Conn) CloseWith(code int, reason string) error {
+	msg := websocket.FormatCloseMessage(code, reason)
+	err := c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+	c.close()
+	if err == websocket.ErrCloseSent {
+		return nil
+	}
+	return err
+}
+
+func (c *Conn) close() {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.conn.Close()
+	})
+}
+
+/*
+定期发送ping，上级上下文取消（如服务强制关闭）时关闭连接
+*/
+func (c *Conn) keepAlive(interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-tick:
+			// WriteControl可以与其他写入并发调用
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				c.close()
+				return
+			}
+		case <-c.ctx.Done():
+			c.close()
+			return
+		}
+	}
+}
+
+// Hub 登记活动连接，停机时统一关闭并等待处理函数返回
+type Hub struct {
+	mu      sync.Mutex
+	conns   map[*Conn]struct{}
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// NewHub 创建Hub
+func NewHub() *Hub {
+	return &Hub{conns: make(map[*Conn]struct{})}
+}
+
+// Add 登记连接，处理结束后须调用Remove。停机中返回ErrShuttingDown
+func (h *Hub) Add(c *Conn) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing {
+		return ErrShuttingDown
+	}
+	h.conns[c] = struct{}{}
+	h.wg.Add(1)
+	return nil
+}
+
+// Remove 移除登记的连接
+func (h *Hub) Remove(c *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.conns[c]; ok {
+		delete(h.conns, c)
+		h.wg.Done()
+	}
+}
+
+// Shutdown 以1001（Going Away）关闭所有连接，并等待处理函数返回，ctx到期后直接返回ctx.Err()
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	conns := make([]*Conn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range conns {
+		c.CloseWith(websocket.CloseGoingAway, "server shutting down")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
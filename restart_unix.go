@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package gowb
+
+import (
+	"os"
+	"syscall"
+)
+
+// restartSignals 触发热重启的信号
+var restartSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR2}
@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package gowb
+
+import "os"
+
+// restartSignals Windows不支持监听器交接
+var restartSignals []os.Signal